@@ -0,0 +1,141 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// activeFor is how long an alert stays active after it was last raised.
+// Monitors raise a condition again on every check while it lasts. Alerts
+// raised with RaiseUntilResolved do not expire.
+const activeFor = 30 * time.Minute
+
+type Alert struct {
+	Key       string    `json:"key"`
+	Message   string    `json:"message"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+
+	untilResolved bool
+}
+
+// Store keeps the active alerts by key, e.g. "tenant-drives/<dns>", so a
+// condition raised on every check is one alert with a count.
+type Store struct {
+	lock   sync.Mutex
+	alerts map[string]*Alert
+}
+
+var defaultStore = NewStore()
+
+func NewStore() *Store {
+	return &Store{alerts: make(map[string]*Alert)}
+}
+
+func GetStore() *Store {
+	return defaultStore
+}
+
+// Raise logs an [ALERT] line at warn level and records the alert in the
+// default store. The line has the key as its alert field and, for the keys
+// of tenant alerts, "tenant-<check>/<dns>[/...]", the DNS as tenant field.
+func Raise(key, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	logAlert(key, message)
+	defaultStore.Raise(key, message)
+}
+
+// RaiseUntilResolved is Raise for a condition that is raised once when it
+// starts, not on every check. The alert stays active until Resolve is called
+// with its key.
+func RaiseUntilResolved(key, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	logAlert(key, message)
+	defaultStore.RaiseUntilResolved(key, message)
+}
+
+func logAlert(key, message string) {
+	ctx := context.Background()
+	logger := slog.Default()
+	if !logger.Enabled(ctx, slog.LevelWarn) {
+		return
+	}
+	// report the caller of Raise as the source
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	record := slog.NewRecord(time.Now(), slog.LevelWarn, "[ALERT] "+message, pcs[0])
+	record.AddAttrs(slog.String("alert", key))
+	if strings.HasPrefix(key, "tenant-") {
+		if parts := strings.Split(key, "/"); len(parts) > 1 {
+			record.AddAttrs(slog.String("tenant", parts[1]))
+		}
+	}
+	logger.Handler().Handle(ctx, record)
+}
+
+// Resolve clears an alert of the default store.
+func Resolve(key string) {
+	defaultStore.Resolve(key)
+}
+
+func (s *Store) Raise(key, message string) {
+	s.raise(key, message, false)
+}
+
+func (s *Store) RaiseUntilResolved(key, message string) {
+	s.raise(key, message, true)
+}
+
+func (s *Store) raise(key, message string, untilResolved bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	a, found := s.alerts[key]
+	if !found || a.expired(now) {
+		a = &Alert{Key: key, FirstSeen: now}
+		s.alerts[key] = a
+	}
+	a.Message = message
+	a.LastSeen = now
+	a.Count++
+	a.untilResolved = a.untilResolved || untilResolved
+}
+
+func (a *Alert) expired(now time.Time) bool {
+	return !a.untilResolved && now.Sub(a.LastSeen) > activeFor
+}
+
+func (s *Store) Resolve(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.alerts, key)
+}
+
+// Active returns the alerts that have not expired or been resolved, most
+// recent first.
+func (s *Store) Active() []Alert {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	active := make([]Alert, 0, len(s.alerts))
+	for key, a := range s.alerts {
+		if a.expired(now) {
+			delete(s.alerts, key)
+			continue
+		}
+		active = append(active, *a)
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastSeen.After(active[j].LastSeen)
+	})
+	return active
+}
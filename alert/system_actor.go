@@ -1,5 +0,0 @@
-package alert
-
-type Alert struct {
-	metricName string
-}
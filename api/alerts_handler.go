@@ -0,0 +1,23 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"encoding/json"
+	"net/http"
+)
+
+type AlertsHandler struct {
+	alertStore *alert.Store
+}
+
+func NewAlertsHandler(alertStore *alert.Store) *AlertsHandler {
+	return &AlertsHandler{
+		alertStore: alertStore,
+	}
+}
+
+// ServeHTTP returns the alerts raised recently, most recent first.
+func (ah *AlertsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ah.alertStore.Active())
+}
@@ -1,26 +1,75 @@
 package api
 
 import (
+	"ChintuIdrive/storage-node-watchdog/alert"
 	"ChintuIdrive/storage-node-watchdog/clients"
 	"ChintuIdrive/storage-node-watchdog/collector"
 	"ChintuIdrive/storage-node-watchdog/conf"
+	"ChintuIdrive/storage-node-watchdog/monitor"
 	"net/http"
 )
 
-func RegisterHandlers(config *conf.Config, cc *clients.ControllerClient, asc *clients.APIserverClient,
-	ssc *collector.SystemStatsCollector, pmc *collector.ProcesMetricsCollector, s3mc *collector.S3MetricCollector) {
+func RegisterHandlers(configStore *conf.ConfigStore, scheduler *monitor.Scheduler, cc *clients.ControllerClient, asc *clients.APIserverClient, tinv *collector.TenantInventory,
+	ssc *collector.SystemStatsCollector, pmc *collector.ProcesMetricsCollector, s3mc *collector.S3MetricCollector,
+	tuc *collector.TenantUsageCollector, mhc *collector.MinioHealthCollector,
+	hsc *collector.HealStatusCollector, tdc *collector.TenantDriftCollector,
+	trc *collector.TenantRestartCollector, trsc *collector.TenantRequestStatsCollector) {
+	// every endpoint needs a credential of http-api.auth once one is configured
+	auth := NewAuthenticator(configStore)
+
 	systemMetricsHandler := NewSystemMetricsHandler(ssc)
-	http.Handle("/system_metrics", systemMetricsHandler)
+	http.Handle("/system_metrics", auth.Read(systemMetricsHandler))
 
 	processMetricsHandler := NewProcessMetricsHandler(pmc)
-	http.Handle("/process_metrics", processMetricsHandler)
+	http.Handle("/process_metrics", auth.Read(processMetricsHandler))
 
 	runningTenantMetricsHandler := NewRunningTenantMetricsHandler(pmc)
-	http.Handle("/running_tenant_metrics", runningTenantMetricsHandler)
+	http.Handle("/running_tenant_metrics", auth.Read(runningTenantMetricsHandler))
+
+	s3handler := NewS3MetricsHandler(s3mc, tinv)
+	http.Handle("/tenant_s3_metrics", auth.Read(s3handler))
+	http.Handle("/all_tenant_s3_metrics", auth.Read(s3handler))
+
+	tenantUsageHandler := NewTenantUsageHandler(tuc, tinv)
+	http.Handle("/tenant_usage", auth.Read(tenantUsageHandler))
+	http.Handle("/all_tenant_usage", auth.Read(tenantUsageHandler))
+
+	minioHealthHandler := NewMinioHealthHandler(mhc, tinv)
+	http.Handle("/tenant_minio_health", auth.Read(minioHealthHandler))
+	http.Handle("/all_tenant_minio_health", auth.Read(minioHealthHandler))
+
+	healStatusHandler := NewHealStatusHandler(hsc, tinv)
+	http.Handle("/tenant_heal_status", auth.Read(healStatusHandler))
+	http.Handle("/all_tenant_heal_status", auth.Read(healStatusHandler))
+
+	tenantDriftHandler := NewTenantDriftHandler(tdc)
+	http.Handle("/tenant_drift", auth.Read(tenantDriftHandler))
+
+	tenantRestartHandler := NewTenantRestartHandler(trc)
+	http.Handle("/tenant_restarts", auth.Read(tenantRestartHandler))
+
+	tenantRequestStatsHandler := NewTenantRequestStatsHandler(trsc)
+	http.Handle("/tenant_request_stats", auth.Read(tenantRequestStatsHandler))
+
+	dependenciesHandler := NewDependenciesHandler(clients.GetDependencyRegistry())
+	http.Handle("/dependencies", auth.Read(dependenciesHandler))
+
+	tenantListStatusHandler := NewTenantListStatusHandler(asc)
+	http.Handle("/tenant_list_status", auth.Read(tenantListStatusHandler))
+
+	tenantInventoryHandler := NewTenantInventoryHandler(tinv)
+	http.Handle("/tenant_inventory", auth.Read(tenantInventoryHandler))
+
+	alertsHandler := NewAlertsHandler(alert.GetStore())
+	http.Handle("/alerts", auth.Read(alertsHandler))
+
+	schedulerHandler := NewSchedulerHandler(scheduler)
+	http.Handle("/scheduler", auth.Read(schedulerHandler))
 
-	s3handler := NewS3MetricsHandler(s3mc, asc)
-	http.Handle("/tenant_s3_metrics", s3handler)
-	http.Handle("/all_tenant_s3_metrics", s3handler)
+	logLevelHandler := NewLogLevelHandler()
+	http.Handle("/log_level", auth.Admin(logLevelHandler))
 
-	http.ListenAndServe(":8080", nil)
+	// the endpoints above are kept for existing clients, new ones use /api/v1/
+	v1Router := NewV1Router(configStore, auth, v1Endpoints(scheduler, asc, tinv, ssc, pmc, s3mc, tuc, mhc, hsc, tdc, trc, trsc))
+	http.Handle(v1Prefix+"/", v1Router)
 }
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A signed request carries
+//
+//	Authorization: HMAC-SHA256 Credential=<key id>, Signature=<hex>
+//	X-Watchdog-Timestamp: <unix seconds>
+//
+// where the signature is the HMAC-SHA256 with the key secret of the string
+// built by stringToSign.
+const (
+	hmacScheme      = "HMAC-SHA256"
+	timestampHeader = "X-Watchdog-Timestamp"
+	// maxSignedBodySize bounds the body read to check a signature.
+	maxSignedBodySize = 1 << 20
+)
+
+var errUnauthenticated = errors.New("missing credentials")
+
+// Authenticator checks the credentials of HTTP API requests against
+// http-api.auth, read again for every request so a reload applies at once.
+type Authenticator struct {
+	configStore *conf.ConfigStore
+}
+
+func NewAuthenticator(configStore *conf.ConfigStore) *Authenticator {
+	return &Authenticator{configStore: configStore}
+}
+
+// Read serves h to credentials with the read or admin scope.
+func (a *Authenticator) Read(h http.Handler) http.Handler {
+	return a.require(conf.APIScopeRead, h)
+}
+
+// Admin serves h to credentials with the admin scope.
+func (a *Authenticator) Admin(h http.Handler) http.Handler {
+	return a.require(conf.APIScopeAdmin, h)
+}
+
+func (a *Authenticator) require(scope string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, message := a.check(w, r, scope); status != 0 {
+			http.Error(w, message, status)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// check returns the status and message to reject r with, or 0 if a
+// credential with scope may be served r. It sets the WWW-Authenticate header
+// of a 401. Without credentials configured the admin scope is refused, and
+// so is the read scope unless client certificates are required or
+// http-api.auth.allow-anonymous-read is set.
+func (a *Authenticator) check(w http.ResponseWriter, r *http.Request, scope string) (int, string) {
+	config := a.configStore.Get()
+	auth := config.GetAPIAuthConfig()
+	if !auth.Enabled() {
+		tlsConfig := config.HTTPAPI.TLS
+		clientCerts := tlsConfig.Enabled() && tlsConfig.ClientCAFile != ""
+		if scope == conf.APIScopeAdmin || !(clientCerts || auth.AllowAnonymousRead) {
+			slog.Warn("Rejected HTTP API request", "path", r.URL.Path, "remote", r.RemoteAddr, "error", "http-api.auth has no credentials")
+			return http.StatusForbidden, "Forbidden, http-api.auth has no tokens or hmac-keys"
+		}
+		return 0, ""
+	}
+	name, granted, err := authenticate(r, auth)
+	if err != nil {
+		if err != errUnauthenticated {
+			slog.Warn("Rejected HTTP API request", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+		}
+		w.Header().Set("WWW-Authenticate", "Bearer, "+hmacScheme)
+		return http.StatusUnauthorized, "Unauthorized"
+	}
+	if scope == conf.APIScopeAdmin && granted != conf.APIScopeAdmin {
+		slog.Warn("Rejected HTTP API request", "path", r.URL.Path, "remote", r.RemoteAddr, "credential", name, "error", "needs the admin scope")
+		return http.StatusForbidden, "Forbidden, needs the admin scope"
+	}
+	return 0, ""
+}
+
+// authenticate returns the name and scope of the credential the request
+// carries.
+func authenticate(r *http.Request, auth conf.APIAuthConfig) (string, string, error) {
+	header := r.Header.Get("Authorization")
+	scheme, credentials, _ := strings.Cut(header, " ")
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+		for _, token := range auth.Tokens {
+			if subtle.ConstantTimeCompare([]byte(credentials), []byte(token.Token)) == 1 {
+				return token.Name, token.Scope, nil
+			}
+		}
+		return "", "", errors.New("unknown bearer token")
+	case scheme == hmacScheme:
+		return verifySignature(r, credentials, auth)
+	}
+	return "", "", errUnauthenticated
+}
+
+func verifySignature(r *http.Request, credentials string, auth conf.APIAuthConfig) (string, string, error) {
+	var keyID, signature string
+	for _, part := range strings.Split(credentials, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "Credential":
+			keyID = value
+		case "Signature":
+			signature = value
+		}
+	}
+	var key *conf.APIHMACKey
+	for i := range auth.HMACKeys {
+		if auth.HMACKeys[i].ID == keyID {
+			key = &auth.HMACKeys[i]
+			break
+		}
+	}
+	if key == nil {
+		return "", "", fmt.Errorf("unknown HMAC key %q", keyID)
+	}
+
+	timestamp := r.Header.Get(timestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid %s %q", timestampHeader, timestamp)
+	}
+	skew := time.Since(time.Unix(seconds, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > auth.MaxClockSkew.Duration {
+		return "", "", fmt.Errorf("%s is %v off, more than %v", timestampHeader, skew.Round(time.Second), auth.MaxClockSkew)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read the body: %w", err)
+	}
+	if len(body) > maxSignedBodySize {
+		return "", "", fmt.Errorf("body of a signed request is over %d bytes", maxSignedBodySize)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	expected := sign(key.Secret, stringToSign(r, timestamp, body))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", "", fmt.Errorf("invalid signature for HMAC key %q", keyID)
+	}
+	return key.ID, key.Scope, nil
+}
+
+// stringToSign covers the method, the path with the query, the timestamp
+// and the body, one per line.
+func stringToSign(r *http.Request, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{hmacScheme, r.Method, r.URL.RequestURI(), timestamp, hex.EncodeToString(bodyHash[:])}, "\n")
+}
+
+func sign(secret, s string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest signs r, whose body is body, with an HMAC key of http-api.auth.
+func SignRequest(r *http.Request, body []byte, key conf.APIHMACKey) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Set(timestampHeader, timestamp)
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, Signature=%s", hmacScheme, key.ID, sign(key.Secret, stringToSign(r, timestamp, body))))
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	readKey  = conf.APIHMACKey{ID: "monitoring", Secret: "read-secret", Scope: conf.APIScopeRead}
+	adminKey = conf.APIHMACKey{ID: "ops", Secret: "admin-secret", Scope: conf.APIScopeAdmin}
+)
+
+func testAuthConfig() conf.APIAuthConfig {
+	return conf.APIAuthConfig{
+		HMACKeys:     []conf.APIHMACKey{readKey, adminKey},
+		MaxClockSkew: conf.NewDuration(5 * time.Minute),
+	}
+}
+
+func newSignedRequest(method, target string, body []byte, key conf.APIHMACKey) *http.Request {
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	SignRequest(r, body, key)
+	return r
+}
+
+// signAt signs r like SignRequest with the timestamp of at.
+func signAt(r *http.Request, body []byte, key conf.APIHMACKey, at time.Time) {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	r.Header.Set(timestampHeader, timestamp)
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, Signature=%s", hmacScheme, key.ID, sign(key.Secret, stringToSign(r, timestamp, body))))
+}
+
+func TestSignRequest(t *testing.T) {
+	body := []byte(`{"level":"debug"}`)
+	r := newSignedRequest(http.MethodPut, "/api/v1/log-level?component=api", body, adminKey)
+
+	name, scope, err := authenticate(r, testAuthConfig())
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if name != adminKey.ID || scope != conf.APIScopeAdmin {
+		t.Errorf("authenticate = %q, %q, want %q, %q", name, scope, adminKey.ID, conf.APIScopeAdmin)
+	}
+	// the handler still reads the whole body
+	read := new(bytes.Buffer)
+	read.ReadFrom(r.Body)
+	if !bytes.Equal(read.Bytes(), body) {
+		t.Errorf("body after authenticate = %q, want %q", read, body)
+	}
+}
+
+func TestVerifySignatureRejects(t *testing.T) {
+	body := []byte(`{"level":"debug"}`)
+	tests := []struct {
+		name    string
+		request func() *http.Request
+	}{
+		{"wrong body", func() *http.Request {
+			r := newSignedRequest(http.MethodPut, "/api/v1/log-level", body, adminKey)
+			r.Body = io.NopCloser(strings.NewReader(`{"level":"error"}`))
+			return r
+		}},
+		{"wrong path", func() *http.Request {
+			r := newSignedRequest(http.MethodGet, "/api/v1/alerts", nil, readKey)
+			r.URL.Path = "/api/v1/inventory"
+			return r
+		}},
+		{"wrong method", func() *http.Request {
+			r := newSignedRequest(http.MethodGet, "/api/v1/log-level", nil, adminKey)
+			r.Method = http.MethodPut
+			return r
+		}},
+		{"wrong secret", func() *http.Request {
+			return newSignedRequest(http.MethodGet, "/api/v1/alerts", nil, conf.APIHMACKey{ID: readKey.ID, Secret: "guess"})
+		}},
+		{"unknown key", func() *http.Request {
+			return newSignedRequest(http.MethodGet, "/api/v1/alerts", nil, conf.APIHMACKey{ID: "other", Secret: readKey.Secret})
+		}},
+		{"timestamp too old", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+			signAt(r, nil, readKey, time.Now().Add(-6*time.Minute))
+			return r
+		}},
+		{"timestamp too far ahead", func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+			signAt(r, nil, readKey, time.Now().Add(6*time.Minute))
+			return r
+		}},
+		{"timestamp changed", func() *http.Request {
+			r := newSignedRequest(http.MethodGet, "/api/v1/alerts", nil, readKey)
+			r.Header.Set(timestampHeader, strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+			return r
+		}},
+		{"timestamp missing", func() *http.Request {
+			r := newSignedRequest(http.MethodGet, "/api/v1/alerts", nil, readKey)
+			r.Header.Del(timestampHeader)
+			return r
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if name, _, err := authenticate(test.request(), testAuthConfig()); err == nil {
+				t.Errorf("authenticate accepted the request as %q", name)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureClockSkew(t *testing.T) {
+	for _, offset := range []time.Duration{-4 * time.Minute, 4 * time.Minute} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+		signAt(r, nil, readKey, time.Now().Add(offset))
+		if _, _, err := authenticate(r, testAuthConfig()); err != nil {
+			t.Errorf("authenticate with a clock %v off: %v", offset, err)
+		}
+	}
+}
+
+func newTestAuthenticator(t *testing.T, config string) *Authenticator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	configStore, err := conf.NewReadOnlyConfigStore(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAuthenticator(configStore)
+}
+
+func TestAuthenticatorScopes(t *testing.T) {
+	auth := newTestAuthenticator(t, `{"schema-version": 1, "http-api": {"auth": {"hmac-keys": [
+		{"id": "monitoring", "secret": "read-secret", "scope": "read"},
+		{"id": "ops", "secret": "admin-secret", "scope": "admin"}]}}}`)
+	tests := []struct {
+		name  string
+		key   *conf.APIHMACKey
+		scope string
+		want  int
+	}{
+		{"read key on read", &readKey, conf.APIScopeRead, 0},
+		{"admin key on read", &adminKey, conf.APIScopeRead, 0},
+		{"admin key on admin", &adminKey, conf.APIScopeAdmin, 0},
+		{"read key on admin", &readKey, conf.APIScopeAdmin, http.StatusForbidden},
+		{"no credentials", nil, conf.APIScopeRead, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+			if test.key != nil {
+				SignRequest(r, nil, *test.key)
+			}
+			w := httptest.NewRecorder()
+			if status, message := auth.check(w, r, test.scope); status != test.want {
+				t.Errorf("check = %d %q, want %d", status, message, test.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorWithoutCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		wantRead  int
+		wantAdmin int
+	}{
+		{"none", `{"schema-version": 1}`, http.StatusForbidden, http.StatusForbidden},
+		{"anonymous read", `{"schema-version": 1, "http-api": {"auth": {"allow-anonymous-read": true}}}`, 0, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			auth := newTestAuthenticator(t, test.config)
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+			if status, _ := auth.check(httptest.NewRecorder(), r, conf.APIScopeRead); status != test.wantRead {
+				t.Errorf("read = %d, want %d", status, test.wantRead)
+			}
+			if status, _ := auth.check(httptest.NewRecorder(), r, conf.APIScopeAdmin); status != test.wantAdmin {
+				t.Errorf("admin = %d, want %d", status, test.wantAdmin)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"encoding/json"
+	"net/http"
+)
+
+type DependenciesHandler struct {
+	dependencyRegistry *clients.DependencyRegistry
+}
+
+func NewDependenciesHandler(dependencyRegistry *clients.DependencyRegistry) *DependenciesHandler {
+	return &DependenciesHandler{
+		dependencyRegistry: dependencyRegistry,
+	}
+}
+
+// ServeHTTP returns the circuit breaker state and last error of every
+// dependency the watchdog has called.
+func (dh *DependenciesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dh.dependencyRegistry.Status())
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type HealStatusHandler struct {
+	healStatusCollector *collector.HealStatusCollector
+	tenantInventory     *collector.TenantInventory
+}
+
+func NewHealStatusHandler(healStatusCollector *collector.HealStatusCollector, tenantInventory *collector.TenantInventory) *HealStatusHandler {
+	return &HealStatusHandler{
+		healStatusCollector: healStatusCollector,
+		tenantInventory:     tenantInventory,
+	}
+}
+
+func (hsh *HealStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/tenant_heal_status":
+		hsh.handleHealStatus(w, r)
+	case "/all_tenant_heal_status":
+		hsh.handleHealStatusForAllTenant(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (hsh *HealStatusHandler) handleHealStatus(w http.ResponseWriter, r *http.Request) {
+	dns := r.URL.Query().Get("dns")
+	if dns == "" {
+		http.Error(w, "Missing dns query parameter", http.StatusBadRequest)
+		return
+	}
+	tenant, found := hsh.tenantInventory.GetTenant(dns)
+	if !found {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
+	healStatus, err := hsh.healStatusCollector.CollectHealStatus(r.Context(), tenant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(healStatus); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (hsh *HealStatusHandler) handleHealStatusForAllTenant(w http.ResponseWriter, r *http.Request) {
+	healStatusMap := make(map[string]*collector.TenantHealStatus)
+	for _, t := range hsh.tenantInventory.GetTenants() {
+		healStatus, err := hsh.healStatusCollector.CollectHealStatus(r.Context(), t)
+		if err != nil {
+			slog.Warn("Failed to collect heal status", "tenant", t.DNS, "error", err)
+			continue
+		}
+		healStatusMap[t.DNS] = healStatus
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(healStatusMap); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
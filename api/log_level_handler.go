@@ -0,0 +1,57 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/logging"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+type LogLevelHandler struct{}
+
+func NewLogLevelHandler() *LogLevelHandler {
+	return &LogLevelHandler{}
+}
+
+// ServeHTTP returns the log level. PUT or POST changes it until the next
+// restart or config reload that changes logging.level, taking the level
+// from the level query parameter or a {"level": "debug"} body.
+func (lh *LogLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		if err := setLogLevel(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LogLevel{Level: logging.Level()})
+}
+
+// setLogLevel sets the level given by the level query parameter or the
+// body of r.
+func setLogLevel(r *http.Request) error {
+	level := LogLevel{Level: r.URL.Query().Get("level")}
+	if level.Level == "" {
+		if err := json.NewDecoder(r.Body).Decode(&level); err != nil {
+			return errors.New("invalid body, expected {\"level\": \"debug|info|warn|error\"}")
+		}
+	}
+	previous := logging.Level()
+	if err := logging.SetLevel(level.Level); err != nil {
+		return fmt.Errorf("invalid level: %w", err)
+	}
+	slog.Warn("Log level changed", "from", previous, "to", logging.Level())
+	return nil
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type MinioHealthHandler struct {
+	minioHealthCollector *collector.MinioHealthCollector
+	tenantInventory      *collector.TenantInventory
+}
+
+func NewMinioHealthHandler(minioHealthCollector *collector.MinioHealthCollector, tenantInventory *collector.TenantInventory) *MinioHealthHandler {
+	return &MinioHealthHandler{
+		minioHealthCollector: minioHealthCollector,
+		tenantInventory:      tenantInventory,
+	}
+}
+
+func (mhh *MinioHealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/tenant_minio_health":
+		mhh.handleMinioHealth(w, r)
+	case "/all_tenant_minio_health":
+		mhh.handleMinioHealthForAllTenant(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (mhh *MinioHealthHandler) handleMinioHealth(w http.ResponseWriter, r *http.Request) {
+	dns := r.URL.Query().Get("dns")
+	if dns == "" {
+		http.Error(w, "Missing dns query parameter", http.StatusBadRequest)
+		return
+	}
+	tenant, found := mhh.tenantInventory.GetTenant(dns)
+	if !found {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
+	minioHealth, err := mhh.minioHealthCollector.CollectMinioHealth(r.Context(), tenant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(minioHealth); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (mhh *MinioHealthHandler) handleMinioHealthForAllTenant(w http.ResponseWriter, r *http.Request) {
+	minioHealthMap := make(map[string]*collector.MinioHealth)
+	for _, t := range mhh.tenantInventory.GetTenants() {
+		minioHealth, err := mhh.minioHealthCollector.CollectMinioHealth(r.Context(), t)
+		if err != nil {
+			slog.Warn("Failed to collect minio health", "tenant", t.DNS, "error", err)
+			continue
+		}
+		minioHealthMap[t.DNS] = minioHealth
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(minioHealthMap); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
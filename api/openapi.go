@@ -0,0 +1,120 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"net/http"
+	"strings"
+)
+
+// openAPIEndpoint serves the OpenAPI document of the versioned API, built
+// from its endpoints so the two cannot disagree. It is the only endpoint
+// answering without an Envelope.
+func (vr *V1Router) openAPIEndpoint() *v1Endpoint {
+	return &v1Endpoint{
+		path: "/openapi.json", summary: "This OpenAPI document",
+		scope: conf.APIScopeRead, methods: []string{http.MethodGet}, raw: true,
+		serve: func(r *http.Request) (interface{}, []APIError, error) {
+			return vr.openAPIDocument(), nil, nil
+		},
+	}
+}
+
+type jsonObject map[string]interface{}
+
+func (vr *V1Router) openAPIDocument() jsonObject {
+	errorResponses := jsonObject{}
+	for status, description := range map[string]string{
+		"400": "Invalid query parameter (invalid_parameter)",
+		"401": "Missing or invalid credentials (unauthorized)",
+		"403": "The credential lacks the admin scope, or no credentials are configured (forbidden)",
+		"404": "Unknown endpoint (not_found) or tenant (unknown_tenant)",
+		"405": "Method not allowed (method_not_allowed)",
+		"500": "Internal error (internal_error)",
+	} {
+		errorResponses[status] = jsonObject{
+			"description": description,
+			"content":     jsonObject{"application/json": jsonObject{"schema": jsonObject{"$ref": "#/components/schemas/Envelope"}}},
+		}
+	}
+
+	paths := jsonObject{}
+	for _, endpoint := range vr.endpoints {
+		parameters := []jsonObject{}
+		for _, param := range endpoint.params {
+			parameters = append(parameters, jsonObject{
+				"name":        param.name,
+				"in":          "query",
+				"description": param.description,
+				"schema":      jsonObject{"type": "string"},
+			})
+		}
+		okSchema := jsonObject{"$ref": "#/components/schemas/Envelope"}
+		if endpoint.raw {
+			okSchema = jsonObject{"type": "object"}
+		}
+		operations := jsonObject{}
+		for _, method := range endpoint.methods {
+			responses := jsonObject{
+				"200": jsonObject{
+					"description": "OK",
+					"content":     jsonObject{"application/json": jsonObject{"schema": okSchema}},
+				},
+			}
+			for status, response := range errorResponses {
+				responses[status] = response
+			}
+			description := "Needs a credential with the " + endpoint.scope + " scope."
+			if endpoint.scope == conf.APIScopeRead {
+				description = "Needs a credential with the read or admin scope."
+			}
+			operations[strings.ToLower(method)] = jsonObject{
+				"summary":     endpoint.summary,
+				"description": description,
+				"parameters":  parameters,
+				"responses":   responses,
+			}
+		}
+		paths[v1Prefix+endpoint.path] = operations
+	}
+
+	return jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":   "storage-node-watchdog",
+			"version": "v1",
+			"description": "Metrics and state of a storage node and its tenants. Until http-api.auth has " +
+				"a token or HMAC key the admin endpoints are refused and so are the read endpoints, " +
+				"unless client certificates are required or http-api.auth.allow-anonymous-read is set. Signed requests carry " +
+				"\"Authorization: HMAC-SHA256 Credential=<key id>, Signature=<hex>\" and X-Watchdog-Timestamp.",
+		},
+		"paths": paths,
+		"components": jsonObject{
+			"schemas": jsonObject{
+				"Envelope": jsonObject{
+					"type":     "object",
+					"required": []string{"node_id", "collected_at", "data"},
+					"properties": jsonObject{
+						"node_id":      jsonObject{"type": "string"},
+						"collected_at": jsonObject{"type": "string", "format": "date-time"},
+						"data":         jsonObject{"nullable": true, "description": "The resource, null on error. Tenant resources are maps by tenant DNS."},
+						"errors":       jsonObject{"type": "array", "items": jsonObject{"$ref": "#/components/schemas/Error"}},
+					},
+				},
+				"Error": jsonObject{
+					"type":     "object",
+					"required": []string{"code", "message"},
+					"properties": jsonObject{
+						"code":    jsonObject{"type": "string"},
+						"message": jsonObject{"type": "string"},
+						"tenant":  jsonObject{"type": "string", "description": "Set on the error of one tenant, the others are in data."},
+					},
+				},
+			},
+			"securitySchemes": jsonObject{
+				"bearer": jsonObject{"type": "http", "scheme": "bearer"},
+				"hmac":   jsonObject{"type": "apiKey", "in": "header", "name": "Authorization"},
+			},
+		},
+		"security": []jsonObject{{"bearer": []string{}}, {"hmac": []string{}}},
+	}
+}
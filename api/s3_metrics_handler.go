@@ -1,23 +1,21 @@
 package api
 
 import (
-	"ChintuIdrive/storage-node-watchdog/clients"
 	"ChintuIdrive/storage-node-watchdog/collector"
-	"ChintuIdrive/storage-node-watchdog/dto"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
 type S3MetricsHandler struct {
 	s3MetricsCollector *collector.S3MetricCollector
-	apiServerClient    *clients.APIserverClient
+	tenantInventory    *collector.TenantInventory
 }
 
-func NewS3MetricsHandler(s3MetricsCollector *collector.S3MetricCollector, apiServerClient *clients.APIserverClient) *S3MetricsHandler {
+func NewS3MetricsHandler(s3MetricsCollector *collector.S3MetricCollector, tenantInventory *collector.TenantInventory) *S3MetricsHandler {
 	return &S3MetricsHandler{
 		s3MetricsCollector: s3MetricsCollector,
-		apiServerClient:    apiServerClient,
+		tenantInventory:    tenantInventory,
 	}
 }
 
@@ -40,22 +38,12 @@ func (s3handler *S3MetricsHandler) handleS3Metrics(w http.ResponseWriter, r *htt
 		http.Error(w, "Missing dns query parameter", http.StatusBadRequest)
 		return
 	}
-	var tenant dto.Tenant
-	tenantsFromApiServer, err := s3handler.apiServerClient.GetTenatsListFromApiServer()
-	if err != nil {
-		log.Printf("Failed to fetch tenant list from API server: %v", err)
-	}
-	for _, t := range tenantsFromApiServer {
-		if t.DNS == dns {
-			tenant = t
-			break
-		}
-	}
-	if tenant.DNS == "" {
+	tenant, found := s3handler.tenantInventory.GetTenant(dns)
+	if !found {
 		http.Error(w, "Invalid tenant", http.StatusBadRequest)
 		return
 	}
-	s3metrics, err := s3handler.s3MetricsCollector.CollectS3Metrics(tenant)
+	s3metrics, err := s3handler.s3MetricsCollector.CollectS3Metrics(r.Context(), tenant)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -69,16 +57,12 @@ func (s3handler *S3MetricsHandler) handleS3Metrics(w http.ResponseWriter, r *htt
 
 func (s3handler *S3MetricsHandler) handleS3StatsForAllTenant(w http.ResponseWriter, r *http.Request) {
 	// Handle another endpoint
-	tenatS3StatsMap := make(map[string]*collector.S3Metrics)
-	tenantsFromApiServer, err := s3handler.apiServerClient.GetTenatsListFromApiServer()
-	if err != nil {
-		log.Printf("Failed to fetch tenant list from API server: %v", err)
-	}
-	for _, t := range tenantsFromApiServer {
-		s3stats, err := s3handler.s3MetricsCollector.CollectS3Metrics(t)
+	tenatS3StatsMap := make(map[string]*collector.TenantS3Metrics)
+	for _, t := range s3handler.tenantInventory.GetTenants() {
+		s3stats, err := s3handler.s3MetricsCollector.CollectS3Metrics(r.Context(), t)
 		if err != nil {
 			//Notify it why it is not able to get the s3 metics
-			log.Printf("Failed to collect S3 metrics for tenant %s: %v", t.DNS, err)
+			slog.Warn("Failed to collect S3 metrics", "tenant", t.DNS, "error", err)
 			continue
 		}
 		tenatS3StatsMap[t.DNS] = s3stats
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/monitor"
+	"encoding/json"
+	"net/http"
+)
+
+type SchedulerHandler struct {
+	scheduler *monitor.Scheduler
+}
+
+func NewSchedulerHandler(scheduler *monitor.Scheduler) *SchedulerHandler {
+	return &SchedulerHandler{
+		scheduler: scheduler,
+	}
+}
+
+// ServeHTTP returns the schedule, last run, next run and duration of every
+// monitor.
+func (sh *SchedulerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sh.scheduler.Status())
+}
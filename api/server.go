@@ -0,0 +1,131 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Timeouts of the HTTP API server. There is no write timeout, the S3 metrics
+// endpoints probe the tenants while the request waits.
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+)
+
+// Serve runs the HTTP API on listen until ctx is done, then stops accepting
+// connections and waits at most shutdownTimeout for in-flight requests. The
+// API is served over HTTPS when tlsConfig is enabled.
+func Serve(ctx context.Context, listen string, tlsConfig conf.APITLSConfig, shutdownTimeout time.Duration) error {
+	server := &http.Server{
+		Addr:              listen,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	if tlsConfig.Enabled() {
+		serverTLS, err := newServerTLSConfig(tlsConfig)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = serverTLS
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		if server.TLSConfig != nil {
+			serveErr <- server.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down the HTTP API")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func newServerTLSConfig(tlsConfig conf.APITLSConfig) (*tls.Config, error) {
+	certs := &certificateLoader{certFile: tlsConfig.CertFile, keyFile: tlsConfig.KeyFile}
+	if _, err := certs.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	serverTLS := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+	}
+	if tlsConfig.ClientCAFile != "" {
+		pem, err := os.ReadFile(tlsConfig.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", tlsConfig.ClientCAFile)
+		}
+		serverTLS.ClientCAs = pool
+		serverTLS.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return serverTLS, nil
+}
+
+// certificateLoader loads the certificate again when its files change, so
+// a renewed certificate is served without a restart.
+type certificateLoader struct {
+	certFile, keyFile string
+
+	lock     sync.Mutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+func (cl *certificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{cl.certFile, cl.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	cl.lock.Lock()
+	defer cl.lock.Unlock()
+	if cl.cert != nil && modTimes == cl.modTimes {
+		return cl.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cl.certFile, cl.keyFile)
+	if err != nil {
+		if cl.cert != nil {
+			// the files may be half written, keep serving the loaded one
+			slog.Error("Failed to reload the HTTP API certificate, keeping the previous one", "error", err)
+			return cl.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load the HTTP API certificate: %w", err)
+	}
+	cl.cert = &cert
+	cl.modTimes = modTimes
+	return cl.cert, nil
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"encoding/json"
+	"net/http"
+)
+
+type TenantDriftHandler struct {
+	tenantDriftCollector *collector.TenantDriftCollector
+}
+
+func NewTenantDriftHandler(tenantDriftCollector *collector.TenantDriftCollector) *TenantDriftHandler {
+	return &TenantDriftHandler{
+		tenantDriftCollector: tenantDriftCollector,
+	}
+}
+
+// ServeHTTP returns the drift found by the last check, for all tenants or
+// for the one given by the dns query parameter.
+func (tdh *TenantDriftHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := tdh.tenantDriftCollector.GetTenantDriftReport()
+	w.Header().Set("Content-Type", "application/json")
+
+	dns := r.URL.Query().Get("dns")
+	if dns == "" {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	tenantDrift, found := report[dns]
+	if !found {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(tenantDrift)
+}
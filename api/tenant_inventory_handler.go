@@ -0,0 +1,24 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"encoding/json"
+	"net/http"
+)
+
+type TenantInventoryHandler struct {
+	tenantInventory *collector.TenantInventory
+}
+
+func NewTenantInventoryHandler(tenantInventory *collector.TenantInventory) *TenantInventoryHandler {
+	return &TenantInventoryHandler{
+		tenantInventory: tenantInventory,
+	}
+}
+
+// ServeHTTP lists the tenants on this node, those assigned by the API server
+// and those the controller runs without an assignment.
+func (tih *TenantInventoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tih.tenantInventory.GetTenantInventoryReport())
+}
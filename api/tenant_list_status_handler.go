@@ -0,0 +1,24 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"encoding/json"
+	"net/http"
+)
+
+type TenantListStatusHandler struct {
+	apiServerClient *clients.APIserverClient
+}
+
+func NewTenantListStatusHandler(asc *clients.APIserverClient) *TenantListStatusHandler {
+	return &TenantListStatusHandler{
+		apiServerClient: asc,
+	}
+}
+
+// ServeHTTP reports whether the monitors work from a fresh tenant list or
+// from the cache, and how stale it is.
+func (tlsh *TenantListStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tlsh.apiServerClient.GetTenantListStatus())
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"encoding/json"
+	"net/http"
+)
+
+type TenantRequestStatsHandler struct {
+	requestStatsCollector *collector.TenantRequestStatsCollector
+}
+
+func NewTenantRequestStatsHandler(requestStatsCollector *collector.TenantRequestStatsCollector) *TenantRequestStatsHandler {
+	return &TenantRequestStatsHandler{
+		requestStatsCollector: requestStatsCollector,
+	}
+}
+
+// ServeHTTP returns the latest request stats, for all tenants or for the
+// one given by the dns query parameter.
+func (trsh *TenantRequestStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := trsh.requestStatsCollector.GetTenantRequestStatsReport()
+	w.Header().Set("Content-Type", "application/json")
+
+	dns := r.URL.Query().Get("dns")
+	if dns == "" {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	requestStats, found := report[dns]
+	if !found {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(requestStats)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"encoding/json"
+	"net/http"
+)
+
+type TenantRestartHandler struct {
+	tenantRestartCollector *collector.TenantRestartCollector
+}
+
+func NewTenantRestartHandler(tenantRestartCollector *collector.TenantRestartCollector) *TenantRestartHandler {
+	return &TenantRestartHandler{
+		tenantRestartCollector: tenantRestartCollector,
+	}
+}
+
+// ServeHTTP returns the restart state and history, for all tenants or for the
+// one given by the dns query parameter.
+func (trh *TenantRestartHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := trh.tenantRestartCollector.GetTenantRestartReport()
+	w.Header().Set("Content-Type", "application/json")
+
+	dns := r.URL.Query().Get("dns")
+	if dns == "" {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	restartState, found := report[dns]
+	if !found {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(restartState)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+type TenantUsageHandler struct {
+	tenantUsageCollector *collector.TenantUsageCollector
+	tenantInventory      *collector.TenantInventory
+}
+
+func NewTenantUsageHandler(tenantUsageCollector *collector.TenantUsageCollector, tenantInventory *collector.TenantInventory) *TenantUsageHandler {
+	return &TenantUsageHandler{
+		tenantUsageCollector: tenantUsageCollector,
+		tenantInventory:      tenantInventory,
+	}
+}
+
+func (tuh *TenantUsageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/tenant_usage":
+		tuh.handleTenantUsage(w, r)
+	case "/all_tenant_usage":
+		tuh.handleUsageForAllTenant(w, r)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (tuh *TenantUsageHandler) handleTenantUsage(w http.ResponseWriter, r *http.Request) {
+	dns := r.URL.Query().Get("dns")
+	if dns == "" {
+		http.Error(w, "Missing dns query parameter", http.StatusBadRequest)
+		return
+	}
+	tenant, found := tuh.tenantInventory.GetTenant(dns)
+	if !found {
+		http.Error(w, "Invalid tenant", http.StatusBadRequest)
+		return
+	}
+	usage, err := tuh.tenantUsageCollector.CollectTenantUsage(r.Context(), tenant)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (tuh *TenantUsageHandler) handleUsageForAllTenant(w http.ResponseWriter, r *http.Request) {
+	tenantUsageMap := make(map[string]*collector.TenantUsage)
+	for _, t := range tuh.tenantInventory.GetTenants() {
+		usage, err := tuh.tenantUsageCollector.CollectTenantUsage(r.Context(), t)
+		if err != nil {
+			slog.Warn("Failed to collect usage", "tenant", t.DNS, "error", err)
+			continue
+		}
+		tenantUsageMap[t.DNS] = usage
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tenantUsageMap); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
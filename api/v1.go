@@ -0,0 +1,262 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// v1Prefix is the namespace of the versioned API.
+const v1Prefix = "/api/v1"
+
+// Error codes of the versioned API.
+const (
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeInvalidParameter = "invalid_parameter"
+	CodeUnknownTenant    = "unknown_tenant"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeCollectionFailed = "collection_failed"
+	CodeInternal         = "internal_error"
+)
+
+// Envelope is the body of every response of the versioned API, errors
+// included.
+type Envelope struct {
+	NodeID      string      `json:"node_id"`
+	CollectedAt time.Time   `json:"collected_at"`
+	Data        interface{} `json:"data"`
+	Errors      []APIError  `json:"errors,omitempty"`
+}
+
+// APIError is the error of a request or, with Tenant set, of one tenant
+// left out of a response that covers several.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Tenant  string `json:"tenant,omitempty"`
+}
+
+// requestError fails a whole request with status.
+type requestError struct {
+	status int
+	APIError
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+func newRequestError(status int, code, format string, args ...interface{}) *requestError {
+	return &requestError{status: status, APIError: APIError{Code: code, Message: fmt.Sprintf(format, args...)}}
+}
+
+type v1Param struct {
+	name        string
+	description string
+}
+
+// v1Endpoint is one resource of the versioned API. serve returns the data,
+// the errors of single tenants and an error failing the request. The data
+// of a raw endpoint is written without an Envelope.
+type v1Endpoint struct {
+	path    string
+	summary string
+	scope   string
+	methods []string
+	params  []v1Param
+	raw     bool
+	serve   func(r *http.Request) (interface{}, []APIError, error)
+}
+
+// V1Router serves the endpoints under /api/v1/. It checks the method, the
+// credentials and the query parameters before an endpoint is called.
+type V1Router struct {
+	configStore *conf.ConfigStore
+	auth        *Authenticator
+	endpoints   []*v1Endpoint
+}
+
+func NewV1Router(configStore *conf.ConfigStore, auth *Authenticator, endpoints []*v1Endpoint) *V1Router {
+	vr := &V1Router{
+		configStore: configStore,
+		auth:        auth,
+	}
+	vr.endpoints = append(endpoints, vr.openAPIEndpoint())
+	return vr
+}
+
+func (vr *V1Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := vr.endpoint(strings.TrimSuffix(r.URL.Path, "/"))
+	if endpoint == nil {
+		vr.writeError(w, r, newRequestError(http.StatusNotFound, CodeNotFound, "no endpoint %s", r.URL.Path))
+		return
+	}
+	if !endpoint.allows(r.Method) {
+		w.Header().Set("Allow", strings.Join(endpoint.methods, ", "))
+		vr.writeError(w, r, newRequestError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "%s does not allow %s", endpoint.path, r.Method))
+		return
+	}
+	if status, message := vr.auth.check(w, r, endpoint.scope); status != 0 {
+		code := CodeUnauthorized
+		if status == http.StatusForbidden {
+			code = CodeForbidden
+		}
+		vr.writeError(w, r, newRequestError(status, code, "%s", message))
+		return
+	}
+	for name := range r.URL.Query() {
+		if !endpoint.accepts(name) {
+			vr.writeError(w, r, newRequestError(http.StatusBadRequest, CodeInvalidParameter, "%s has no query parameter %s", endpoint.path, name))
+			return
+		}
+	}
+
+	data, tenantErrs, err := endpoint.serve(r)
+	if err != nil {
+		var reqErr *requestError
+		if !errors.As(err, &reqErr) {
+			slog.Error("HTTP API request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+			reqErr = newRequestError(http.StatusInternalServerError, CodeInternal, "%v", err)
+		}
+		vr.writeError(w, r, reqErr)
+		return
+	}
+	if endpoint.raw {
+		vr.writeJSON(w, r, http.StatusOK, data)
+		return
+	}
+	vr.write(w, r, http.StatusOK, data, tenantErrs)
+}
+
+func (vr *V1Router) endpoint(path string) *v1Endpoint {
+	for _, endpoint := range vr.endpoints {
+		if v1Prefix+endpoint.path == path {
+			return endpoint
+		}
+	}
+	return nil
+}
+
+func (endpoint *v1Endpoint) allows(method string) bool {
+	for _, allowed := range endpoint.methods {
+		if method == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (endpoint *v1Endpoint) accepts(param string) bool {
+	for _, p := range endpoint.params {
+		if p.name == param {
+			return true
+		}
+	}
+	return false
+}
+
+func (vr *V1Router) writeError(w http.ResponseWriter, r *http.Request, err *requestError) {
+	vr.write(w, r, err.status, nil, []APIError{err.APIError})
+}
+
+func (vr *V1Router) write(w http.ResponseWriter, r *http.Request, status int, data interface{}, errs []APIError) {
+	envelope := Envelope{
+		CollectedAt: time.Now().UTC(),
+		Data:        data,
+		Errors:      errs,
+	}
+	if asc := vr.configStore.Get().ApiServerConfig; asc != nil {
+		envelope.NodeID = asc.NodeId
+	}
+	vr.writeJSON(w, r, status, envelope)
+}
+
+// writeJSON writes v, or an internal error if v can't be encoded.
+func (vr *V1Router) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("Failed to encode HTTP API response", "method", r.Method, "path", r.URL.Path, "error", err)
+		vr.writeError(w, r, newRequestError(http.StatusInternalServerError, CodeInternal, "failed to encode the response: %v", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(append(body, '\n')); err != nil {
+		slog.Warn("Failed to write HTTP API response", "method", r.Method, "path", r.URL.Path, "error", err)
+	}
+}
+
+// queryList returns the values of a query parameter given as a comma
+// separated list, repeated, or both.
+func queryList(r *http.Request, name string) []string {
+	var values []string
+	for _, value := range r.URL.Query()[name] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// selectTenants returns the tenants named by the tenant query parameter,
+// all tenants of the inventory without it.
+func selectTenants(r *http.Request, tinv *collector.TenantInventory) ([]dto.Tenant, error) {
+	names := queryList(r, "tenant")
+	if len(names) == 0 {
+		return tinv.GetTenants(), nil
+	}
+	tenants := make([]dto.Tenant, 0, len(names))
+	for _, name := range names {
+		tenant, found := tinv.GetTenant(name)
+		if !found {
+			return nil, newRequestError(http.StatusNotFound, CodeUnknownTenant, "tenant %s is not on this node", name)
+		}
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].DNS < tenants[j].DNS
+	})
+	return tenants, nil
+}
+
+// tenantSet returns the DNS of the tenants selected by the tenant query
+// parameter.
+func tenantSet(r *http.Request, tinv *collector.TenantInventory) (map[string]bool, error) {
+	tenants, err := selectTenants(r, tinv)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(tenants))
+	for _, tenant := range tenants {
+		set[tenant.DNS] = true
+	}
+	return set, nil
+}
+
+// collectPerTenant collects for each tenant, by DNS. A tenant that fails
+// is left out and reported as an error.
+func collectPerTenant(tenants []dto.Tenant, collect func(tenant dto.Tenant) (interface{}, error)) (map[string]interface{}, []APIError) {
+	data := make(map[string]interface{}, len(tenants))
+	var errs []APIError
+	for _, tenant := range tenants {
+		result, err := collect(tenant)
+		if err != nil {
+			errs = append(errs, APIError{Code: CodeCollectionFailed, Message: err.Error(), Tenant: tenant.DNS})
+			continue
+		}
+		data[tenant.DNS] = result
+	}
+	return data, errs
+}
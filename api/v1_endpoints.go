@@ -0,0 +1,228 @@
+package api
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"ChintuIdrive/storage-node-watchdog/logging"
+	"ChintuIdrive/storage-node-watchdog/monitor"
+	"net/http"
+)
+
+var tenantParam = v1Param{name: "tenant", description: "DNS of the tenants to include, comma separated or repeated. All tenants of the node without it."}
+
+// v1Endpoints lists the resources of the versioned API. Tenant resources
+// are maps by tenant DNS.
+func v1Endpoints(scheduler *monitor.Scheduler, asc *clients.APIserverClient, tinv *collector.TenantInventory,
+	ssc *collector.SystemStatsCollector, pmc *collector.ProcesMetricsCollector, s3mc *collector.S3MetricCollector,
+	tuc *collector.TenantUsageCollector, mhc *collector.MinioHealthCollector,
+	hsc *collector.HealStatusCollector, tdc *collector.TenantDriftCollector,
+	trc *collector.TenantRestartCollector, trsc *collector.TenantRequestStatsCollector) []*v1Endpoint {
+	get := []string{http.MethodGet}
+	return []*v1Endpoint{
+		{
+			path: "/system", summary: "Load, CPU, memory and disk metrics of the node",
+			scope: conf.APIScopeRead, methods: get,
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				return ssc.CollectSystemMetrics(), nil, nil
+			},
+		},
+		{
+			path: "/processes", summary: "Metrics of the monitored processes",
+			scope: conf.APIScopeRead, methods: get,
+			params: []v1Param{{name: "process", description: "Names of the processes to include, comma separated or repeated, e.g. kes."}},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				names := queryList(r, "process")
+				processes := pmc.CollectProcessMetrics()
+				if len(names) == 0 {
+					return processes, nil, nil
+				}
+				selected := make(map[string]bool, len(names))
+				for _, name := range names {
+					selected[name] = true
+				}
+				filtered := make([]collector.ProcessMetrics, 0, len(processes))
+				for _, process := range processes {
+					if selected[process.Name] {
+						filtered = append(filtered, process)
+					}
+				}
+				return filtered, nil, nil
+			},
+		},
+		{
+			path: "/tenants", summary: "Tenants on this node, assigned by the API server or run by the controller",
+			scope: conf.APIScopeRead, methods: get,
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				return tinv.GetTenantInventoryReport(), nil, nil
+			},
+		},
+		{
+			path: "/tenants/list-status", summary: "Whether the tenant list is fresh or served from the cache",
+			scope: conf.APIScopeRead, methods: get,
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				return asc.GetTenantListStatus(), nil, nil
+			},
+		},
+		{
+			path: "/tenants/processes", summary: "Metrics of the running tenant processes",
+			scope: conf.APIScopeRead, methods: get, params: []v1Param{tenantParam},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				selected, err := tenantSet(r, tinv)
+				if err != nil {
+					return nil, nil, err
+				}
+				data := make(map[string]collector.TenantProcessMetrics)
+				for _, process := range pmc.CollectRunningTenantProcMetrics() {
+					if selected[process.DNS] {
+						data[process.DNS] = process
+					}
+				}
+				return data, nil, nil
+			},
+		},
+		{
+			path: "/tenants/s3-metrics", summary: "Results of the S3 probes of the tenants",
+			scope: conf.APIScopeRead, methods: get, params: []v1Param{tenantParam},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				tenants, err := selectTenants(r, tinv)
+				if err != nil {
+					return nil, nil, err
+				}
+				data, errs := collectPerTenant(tenants, func(tenant dto.Tenant) (interface{}, error) {
+					return s3mc.CollectS3Metrics(r.Context(), tenant)
+				})
+				return data, errs, nil
+			},
+		},
+		{
+			path: "/tenants/usage", summary: "Bucket and object usage of the tenants",
+			scope: conf.APIScopeRead, methods: get, params: []v1Param{tenantParam},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				tenants, err := selectTenants(r, tinv)
+				if err != nil {
+					return nil, nil, err
+				}
+				data, errs := collectPerTenant(tenants, func(tenant dto.Tenant) (interface{}, error) {
+					return tuc.CollectTenantUsage(r.Context(), tenant)
+				})
+				return data, errs, nil
+			},
+		},
+		{
+			path: "/tenants/minio-health", summary: "Server, drive and erasure set health of the tenants",
+			scope: conf.APIScopeRead, methods: get, params: []v1Param{tenantParam},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				tenants, err := selectTenants(r, tinv)
+				if err != nil {
+					return nil, nil, err
+				}
+				data, errs := collectPerTenant(tenants, func(tenant dto.Tenant) (interface{}, error) {
+					return mhc.CollectMinioHealth(r.Context(), tenant)
+				})
+				return data, errs, nil
+			},
+		},
+		{
+			path: "/tenants/heal-status", summary: "Healing progress of the tenants",
+			scope: conf.APIScopeRead, methods: get, params: []v1Param{tenantParam},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				tenants, err := selectTenants(r, tinv)
+				if err != nil {
+					return nil, nil, err
+				}
+				data, errs := collectPerTenant(tenants, func(tenant dto.Tenant) (interface{}, error) {
+					return hsc.CollectHealStatus(r.Context(), tenant)
+				})
+				return data, errs, nil
+			},
+		},
+		{
+			path: "/tenants/drift", summary: "Differences between the running tenants and the API server found by the last check",
+			scope: conf.APIScopeRead, methods: get, params: []v1Param{tenantParam},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				selected, err := tenantSet(r, tinv)
+				if err != nil {
+					return nil, nil, err
+				}
+				data := make(map[string]*collector.TenantDrift)
+				for dns, drift := range tdc.GetTenantDriftReport() {
+					if selected[dns] {
+						data[dns] = drift
+					}
+				}
+				return data, nil, nil
+			},
+		},
+		{
+			path: "/tenants/restarts", summary: "Restart state and history of the tenants",
+			scope: conf.APIScopeRead, methods: get, params: []v1Param{tenantParam},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				selected, err := tenantSet(r, tinv)
+				if err != nil {
+					return nil, nil, err
+				}
+				data := make(map[string]*collector.TenantRestartState)
+				for dns, state := range trc.GetTenantRestartReport() {
+					if selected[dns] {
+						data[dns] = state
+					}
+				}
+				return data, nil, nil
+			},
+		},
+		{
+			path: "/tenants/request-stats", summary: "Latest S3 request rates and errors of the tenants",
+			scope: conf.APIScopeRead, methods: get, params: []v1Param{tenantParam},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				selected, err := tenantSet(r, tinv)
+				if err != nil {
+					return nil, nil, err
+				}
+				data := make(map[string]*collector.TenantRequestStats)
+				for dns, stats := range trsc.GetTenantRequestStatsReport() {
+					if selected[dns] {
+						data[dns] = stats
+					}
+				}
+				return data, nil, nil
+			},
+		},
+		{
+			path: "/dependencies", summary: "Circuit breaker state of the API server and controller",
+			scope: conf.APIScopeRead, methods: get,
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				return clients.GetDependencyRegistry().Status(), nil, nil
+			},
+		},
+		{
+			path: "/alerts", summary: "Active alerts, most recent first",
+			scope: conf.APIScopeRead, methods: get,
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				return alert.GetStore().Active(), nil, nil
+			},
+		},
+		{
+			path: "/scheduler", summary: "Schedule, last and next run of every monitor",
+			scope: conf.APIScopeRead, methods: get,
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				return scheduler.Status(), nil, nil
+			},
+		},
+		{
+			path: "/log-level", summary: "Log level, PUT changes it until the next restart",
+			scope: conf.APIScopeAdmin, methods: []string{http.MethodGet, http.MethodPut},
+			params: []v1Param{{name: "level", description: "New level for PUT: debug, info, warn or error. A {\"level\": ...} body works too."}},
+			serve: func(r *http.Request) (interface{}, []APIError, error) {
+				if r.Method == http.MethodPut {
+					if err := setLogLevel(r); err != nil {
+						return nil, nil, newRequestError(http.StatusBadRequest, CodeInvalidParameter, "%v", err)
+					}
+				}
+				return LogLevel{Level: logging.Level()}, nil, nil
+			},
+		},
+	}
+}
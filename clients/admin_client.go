@@ -1,11 +1,15 @@
 package clients
 
 import (
+	"ChintuIdrive/storage-node-watchdog/cryption"
+	"ChintuIdrive/storage-node-watchdog/dto"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/minio/madmin-go/v3"
 	"github.com/minio/mc/pkg/probe"
@@ -57,7 +61,7 @@ func NewAdminClient(endpoint, accessKey, secretKey string) (*AdminClient, *probe
 	// Not found. Instantiate a new MinIO
 	api, e = madmin.NewWithOptions(hostName, &madmin.Options{
 		Creds:  creds,
-		Secure: true,
+		Secure: targetURL.Scheme != "http",
 	})
 	if e != nil {
 		return nil, probe.NewError(e)
@@ -72,11 +76,68 @@ func NewAdminClient(endpoint, accessKey, secretKey string) (*AdminClient, *probe
 	return &AdminClient{client: api}, nil
 }
 
-func (c *AdminClient) AddCannedPolicy(policyName string, policy string) error {
-	return c.client.AddCannedPolicy(context.TODO(), policyName, []byte(policy))
+// NewTenantAdminClient creates an admin client for the tenant's minio process
+// on this node, authenticated with the tenant's root credentials.
+func NewTenantAdminClient(tenant dto.Tenant, processInfo *dto.TenantProcessInfoResponse) (*AdminClient, error) {
+	password, err := cryption.SString{CString: tenant.Password.CString}.GetDString()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt password for tenant %s: %v", tenant.DNS, err)
+	}
+	scheme := "https://"
+	if processInfo.TenatWithProcessInfo.UseHttp {
+		scheme = "http://"
+	}
+	adminClient, perr := NewAdminClient(scheme+processInfo.LocalS3Endpoint(), tenant.UserID, password)
+	if perr != nil {
+		return nil, perr.ToGoError()
+	}
+	return adminClient, nil
+}
+
+// withAdminTimeout bounds an admin API call by the timeout of the HTTP
+// client config, madmin has no timeout of its own.
+func withAdminTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	_, hcc := getHTTPClient()
+	return context.WithTimeout(ctx, hcc.Timeout.Duration)
+}
+
+func (c *AdminClient) DataUsageInfo(ctx context.Context) (madmin.DataUsageInfo, error) {
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.DataUsageInfo(ctx)
+}
+
+func (c *AdminClient) AccountInfo(ctx context.Context) (madmin.AccountInfo, error) {
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.AccountInfo(ctx, madmin.AccountOpts{})
+}
+
+func (c *AdminClient) ServerInfo(ctx context.Context) (madmin.InfoMessage, error) {
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.ServerInfo(ctx)
+}
+
+func (c *AdminClient) StorageInfo(ctx context.Context) (madmin.StorageInfo, error) {
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.StorageInfo(ctx)
+}
+
+func (c *AdminClient) BackgroundHealStatus(ctx context.Context) (madmin.BgHealState, error) {
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.BackgroundHealStatus(ctx)
+}
+
+func (c *AdminClient) AddCannedPolicy(ctx context.Context, policyName string, policy string) error {
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.AddCannedPolicy(ctx, policyName, []byte(policy))
 }
 
-func (c *AdminClient) AddNewServiceAccount(policy, accessKey, secretKey, name, description string) (madmin.Credentials, error) {
+func (c *AdminClient) AddNewServiceAccount(ctx context.Context, policy, accessKey, secretKey, name, description string) (madmin.Credentials, error) {
 	opts := madmin.AddServiceAccountReq{
 		Policy:      []byte(policy),
 		AccessKey:   accessKey,
@@ -84,14 +145,28 @@ func (c *AdminClient) AddNewServiceAccount(policy, accessKey, secretKey, name, d
 		Name:        name,
 		Description: description,
 	}
-	return c.client.AddServiceAccount(context.TODO(), opts)
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.AddServiceAccount(ctx, opts)
+}
+
+func (c *AdminClient) UpdateServiceAccount(ctx context.Context, accessKey, policy string, expiration time.Time) error {
+	opts := madmin.UpdateServiceAccountReq{
+		NewPolicy:     []byte(policy),
+		NewExpiration: &expiration,
+	}
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.UpdateServiceAccount(ctx, accessKey, opts)
 }
 
-func (c *AdminClient) DeleteServiceAccount(accessKey string) error {
-	return c.client.DeleteServiceAccount(context.TODO(), accessKey)
+func (c *AdminClient) DeleteServiceAccount(ctx context.Context, accessKey string) error {
+	ctx, cancel := withAdminTimeout(ctx)
+	defer cancel()
+	return c.client.DeleteServiceAccount(ctx, accessKey)
 }
 
-func (c *AdminClient) AddNotificationCredentials(arns []string, accessKey, secretKey string) (res *http.Response, err error) {
+func (c *AdminClient) AddNotificationCredentials(ctx context.Context, arns []string, accessKey, secretKey string) (res *http.Response, err error) {
 	b, _ := json.Marshal(arns)
 	adminPayloadFmt := `{
     "arns": %s,
@@ -116,10 +191,10 @@ func (c *AdminClient) AddNotificationCredentials(arns []string, accessKey, secre
 	reqData.RelPath = "/v3/add-notification-credentials"
 	//reqData.QueryValues = queryValues
 
-	return c.client.ExecuteMethod(context.TODO(), http.MethodPost, reqData)
+	return c.executeMethod(ctx, reqData)
 }
 
-func (c *AdminClient) RemoveNotificationCredentials(arns []string) (res *http.Response, err error) {
+func (c *AdminClient) RemoveNotificationCredentials(ctx context.Context, arns []string) (res *http.Response, err error) {
 	b, _ := json.Marshal(arns)
 	adminPayloadFmt := `{
     "arns": %s
@@ -141,5 +216,28 @@ func (c *AdminClient) RemoveNotificationCredentials(arns []string) (res *http.Re
 	reqData.Content = encryptedData
 	reqData.RelPath = "/v3/remove-notification-credentials"
 
-	return c.client.ExecuteMethod(context.TODO(), http.MethodPost, reqData)
+	return c.executeMethod(ctx, reqData)
+}
+
+// executeMethod posts reqData under the admin timeout, which is cancelled
+// when the body of the response is closed.
+func (c *AdminClient) executeMethod(ctx context.Context, reqData madmin.RequestData) (*http.Response, error) {
+	ctx, cancel := withAdminTimeout(ctx)
+	res, err := c.client.ExecuteMethod(ctx, http.MethodPost, reqData)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
 }
@@ -3,70 +3,169 @@ package clients
 import (
 	"ChintuIdrive/storage-node-watchdog/conf"
 	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
-	"strings"
+	"log/slog"
+	"sync"
+	"time"
 )
 
 type APIserverClient struct {
-	apiserverConfig *conf.ApiServerConfig
+	configLock       sync.RWMutex
+	apiserverConfig  *conf.ApiServerConfig
+	controllerClient *ControllerClient
+	tenantListCache  *TenantListCache
+	statusLock       sync.RWMutex
+	tenantListStatus TenantListStatus
 }
 
-func NewApiServerClient(apiserverConfig *conf.ApiServerConfig) *APIserverClient {
+func NewApiServerClient(apiserverConfig *conf.ApiServerConfig, cc *ControllerClient) *APIserverClient {
 	return &APIserverClient{
-		apiserverConfig: apiserverConfig,
+		apiserverConfig:  apiserverConfig,
+		controllerClient: cc,
+		tenantListCache:  NewTenantListCache(apiserverConfig.GetTenantListCacheFile()),
 	}
 }
 
-func (asc *APIserverClient) GetTenatsListFromApiServer() ([]dto.Tenant, error) {
+// SetConfig switches to a reloaded configuration, a new cache file starts
+// out empty.
+func (asc *APIserverClient) SetConfig(apiserverConfig *conf.ApiServerConfig) {
+	asc.configLock.Lock()
+	defer asc.configLock.Unlock()
+	if apiserverConfig.GetTenantListCacheFile() != asc.apiserverConfig.GetTenantListCacheFile() {
+		asc.tenantListCache = NewTenantListCache(apiserverConfig.GetTenantListCacheFile())
+	}
+	asc.apiserverConfig = apiserverConfig
+}
 
-	var tenatList []dto.Tenant
+func (asc *APIserverClient) getConfig() (*conf.ApiServerConfig, *TenantListCache) {
+	asc.configLock.RLock()
+	defer asc.configLock.RUnlock()
+	return asc.apiserverConfig, asc.tenantListCache
+}
 
-	url := fmt.Sprintf("https://%s/%s", asc.apiserverConfig.APIServerDNS, asc.apiserverConfig.TenantListApi)
-	method := "POST"
+// FlushTenantListCache writes the last tenant list to disk if saving it
+// failed when it was fetched.
+func (asc *APIserverClient) FlushTenantListCache() error {
+	_, tenantListCache := asc.getConfig()
+	return tenantListCache.Flush()
+}
+
+func (asc *APIserverClient) GetTenatsListFromApiServer(ctx context.Context) ([]dto.Tenant, error) {
 
-	payload := []byte(fmt.Sprintf(`{"NodeId":"%s"}`, asc.apiserverConfig.NodeId))
+	var tenatList []dto.Tenant
 
-	res, err := FireRequest(method, url, payload)
+	nodeInfo, err := asc.GetNodeInfoFromApiServer(ctx)
 	if err != nil {
 		return tenatList, err
 	}
+	tenatList = nodeInfo.TenantList
+	return tenatList, err
 
-	defer res.Body.Close()
+}
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		fmt.Println(err)
-		return tenatList, err
+// GetNodeInfoFromApiServer returns the full tenant list response, including
+// the node level healing limits set by the API server. While the API server
+// is unreachable the last list it returned is served instead, see
+// GetTenantListStatus for how stale it is.
+func (asc *APIserverClient) GetNodeInfoFromApiServer(ctx context.Context) (*dto.TenantList, error) {
+	apiserverConfig, tenantListCache := asc.getConfig()
+	nodeInfo, err := asc.fetchNodeInfo(ctx, apiserverConfig)
+	if err == nil {
+		fetchedAt := time.Now()
+		if err := tenantListCache.Save(nodeInfo, fetchedAt); err != nil {
+			slog.Error("Failed to cache tenant list", "error", err)
+		}
+		asc.setTenantListStatus(TenantListStatus{
+			Source:    TenantListFromAPIServer,
+			FetchedAt: fetchedAt,
+			Tenants:   len(nodeInfo.TenantList),
+		})
+		return nodeInfo, nil
 	}
-	var nodeInfo dto.TenantList
+	return asc.getFallbackNodeInfo(apiserverConfig, tenantListCache, err)
+}
 
-	err = json.Unmarshal(body, &nodeInfo)
-	if err != nil {
-		return tenatList, err
+// getFallbackNodeInfo serves the cached tenant list, merged with the tenants
+// the controller runs if configured. It returns fetchErr if neither is there.
+func (asc *APIserverClient) getFallbackNodeInfo(apiserverConfig *conf.ApiServerConfig, tenantListCache *TenantListCache, fetchErr error) (*dto.TenantList, error) {
+	status := TenantListStatus{LastError: fetchErr.Error()}
+	nodeInfo, fetchedAt, cacheErr := tenantListCache.Load()
+	if cacheErr == nil {
+		status.Source = TenantListFromCache
+		status.FetchedAt = fetchedAt
+		status.Age = time.Since(fetchedAt)
 	} else {
-		tenatList = nodeInfo.TenantList
+		nodeInfo = &dto.TenantList{}
 	}
-	return tenatList, err
 
+	if apiserverConfig.GetMergeControllerTenants() && asc.controllerClient != nil {
+		running, err := asc.controllerClient.GetTenantListFromController()
+		if err != nil {
+			slog.Warn("Failed to read running tenants from controller", "error", err)
+		} else if len(running) > 0 {
+			nodeInfo.TenantList = mergeRunningTenants(nodeInfo.TenantList, running)
+			if cacheErr == nil {
+				status.Source = TenantListFromCacheAndController
+			} else {
+				status.Source = TenantListFromController
+			}
+		}
+	}
+
+	if status.Source == "" {
+		return nil, fmt.Errorf("%w (%v)", fetchErr, cacheErr)
+	}
+	status.Tenants = len(nodeInfo.TenantList)
+	asc.setTenantListStatus(status)
+	slog.Warn("API server unreachable, using fallback tenant list", "source", status.Source, "age", status.Age.Round(time.Second), "error", fetchErr)
+	return nodeInfo, nil
+}
+
+func (asc *APIserverClient) setTenantListStatus(status TenantListStatus) {
+	asc.statusLock.Lock()
+	defer asc.statusLock.Unlock()
+	asc.tenantListStatus = status
 }
 
-func FireRequest(method, url string, payload []byte) (*http.Response, error) {
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, strings.NewReader(string(payload)))
+// GetTenantListStatus tells where the last tenant list came from and, when
+// it came from the cache, how old it is.
+func (asc *APIserverClient) GetTenantListStatus() TenantListStatus {
+	asc.statusLock.RLock()
+	defer asc.statusLock.RUnlock()
+	status := asc.tenantListStatus
+	if status.Source != TenantListFromAPIServer && !status.FetchedAt.IsZero() {
+		status.Age = time.Since(status.FetchedAt)
+	}
+	return status
+}
+
+func (asc *APIserverClient) fetchNodeInfo(ctx context.Context, apiserverConfig *conf.ApiServerConfig) (*dto.TenantList, error) {
+
+	url := fmt.Sprintf("https://%s/%s", apiserverConfig.APIServerDNS, apiserverConfig.TenantListApi)
+	method := "POST"
+
+	payload := []byte(fmt.Sprintf(`{"NodeId":"%s"}`, apiserverConfig.NodeId))
+
+	res, err := FireDependencyRequest(ctx, DependencyAPIServer, method, url, payload, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
 
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Content-Type", "application/json")
+	var nodeInfo dto.TenantList
 
-	res, err := client.Do(req)
+	err = json.Unmarshal(body, &nodeInfo)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
+	return &nodeInfo, nil
 
-	return res, nil
 }
@@ -0,0 +1,266 @@
+package clients
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// Dependency names used for the breakers
+const (
+	DependencyAPIServer  = "api-server"
+	DependencyController = "controller"
+)
+
+// TenantS3Dependency names the breaker of a tenant S3 endpoint, public or local.
+func TenantS3Dependency(dns, endpoint string) string {
+	return fmt.Sprintf("tenant-s3/%s/%s", endpoint, dns)
+}
+
+// CircuitOpenError is returned instead of calling a dependency whose circuit is open.
+type CircuitOpenError struct {
+	Dependency string
+	RetryAt    time.Time
+	LastError  string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s unreachable, next try at %s: %s", e.Dependency, e.RetryAt.Format(time.RFC3339), e.LastError)
+}
+
+func IsCircuitOpen(err error) bool {
+	var openErr *CircuitOpenError
+	return errors.As(err, &openErr)
+}
+
+type DependencyStatus struct {
+	Name                string       `json:"name"`
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	LastError           string       `json:"last_error,omitempty"`
+	LastFailure         time.Time    `json:"last_failure,omitempty"`
+	LastSuccess         time.Time    `json:"last_success,omitempty"`
+	OpenedAt            time.Time    `json:"opened_at,omitempty"`
+}
+
+// CircuitBreaker stops calls to a dependency after FailureThreshold
+// consecutive failures. After CoolDown a single trial call is let through,
+// its result closes the circuit again or restarts the cool down.
+type CircuitBreaker struct {
+	config        conf.CircuitBreakerConfig
+	lock          sync.Mutex
+	status        DependencyStatus
+	trialInFlight bool
+}
+
+func NewCircuitBreaker(name string, config conf.CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		config: config,
+		status: DependencyStatus{Name: name, State: CircuitClosed},
+	}
+}
+
+// Allow returns an CircuitOpenError if the dependency must not be called now.
+func (cb *CircuitBreaker) Allow() error {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	switch cb.status.State {
+	case CircuitOpen:
+		retryAt := cb.status.OpenedAt.Add(cb.config.CoolDown.Duration)
+		if time.Now().Before(retryAt) {
+			return &CircuitOpenError{Dependency: cb.status.Name, RetryAt: retryAt, LastError: cb.status.LastError}
+		}
+		cb.status.State = CircuitHalfOpen
+		cb.trialInFlight = true
+		return nil
+	case CircuitHalfOpen:
+		if cb.trialInFlight {
+			return &CircuitOpenError{Dependency: cb.status.Name, RetryAt: time.Now().Add(cb.config.CoolDown.Duration), LastError: cb.status.LastError}
+		}
+		cb.trialInFlight = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record updates the breaker with the result of a call let through by Allow.
+func (cb *CircuitBreaker) Record(err error) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	now := time.Now()
+	cb.trialInFlight = false
+	if err == nil {
+		if cb.status.State != CircuitClosed {
+			slog.Info("Dependency is reachable again", "dependency", cb.status.Name)
+			alert.Resolve("dependency/" + cb.status.Name)
+		}
+		cb.status.State = CircuitClosed
+		cb.status.ConsecutiveFailures = 0
+		cb.status.LastSuccess = now
+		return
+	}
+
+	cb.status.ConsecutiveFailures++
+	cb.status.LastError = err.Error()
+	cb.status.LastFailure = now
+	switch {
+	case cb.status.State == CircuitHalfOpen:
+		cb.status.State = CircuitOpen
+		cb.status.OpenedAt = now
+	case cb.status.State == CircuitClosed && cb.status.ConsecutiveFailures >= cb.config.FailureThreshold:
+		cb.status.State = CircuitOpen
+		cb.status.OpenedAt = now
+		alert.RaiseUntilResolved("dependency/"+cb.status.Name, "Dependency %s unreachable after %d consecutive failures: %v", cb.status.Name, cb.status.ConsecutiveFailures, err)
+	}
+}
+
+// releaseTrial lets the next trial through without changing the state, the
+// call let through by Allow did not tell whether the dependency is up.
+func (cb *CircuitBreaker) releaseTrial() {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.trialInFlight = false
+}
+
+func (cb *CircuitBreaker) Status() DependencyStatus {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	return cb.status
+}
+
+// DependencyRegistry holds one circuit breaker per dependency.
+type DependencyRegistry struct {
+	config   conf.CircuitBreakerConfig
+	lock     sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+var dependencyRegistry = NewDependencyRegistry((&conf.Config{}).GetCircuitBreakerConfig())
+
+func NewDependencyRegistry(config conf.CircuitBreakerConfig) *DependencyRegistry {
+	return &DependencyRegistry{
+		config:   config,
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
+
+// ConfigureDependencies sets the circuit breaker settings of every
+// dependency, keeping the state of their breakers.
+func ConfigureDependencies(config conf.CircuitBreakerConfig) {
+	dependencyRegistry.SetConfig(config)
+}
+
+// GetDependencyRegistry returns the registry shared by all clients.
+func GetDependencyRegistry() *DependencyRegistry {
+	return dependencyRegistry
+}
+
+func (dr *DependencyRegistry) SetConfig(config conf.CircuitBreakerConfig) {
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	dr.config = config
+	for _, cb := range dr.breakers {
+		cb.lock.Lock()
+		cb.config = config
+		cb.lock.Unlock()
+	}
+}
+
+// Breaker returns the breaker of the named dependency, creating it on first use.
+func (dr *DependencyRegistry) Breaker(name string) *CircuitBreaker {
+	dr.lock.Lock()
+	defer dr.lock.Unlock()
+	cb, found := dr.breakers[name]
+	if !found {
+		cb = NewCircuitBreaker(name, dr.config)
+		dr.breakers[name] = cb
+	}
+	return cb
+}
+
+// Call runs fn unless the circuit of the dependency is open and records the
+// result. Errors for which isFailure returns false count as success, the
+// dependency answered. Nothing is recorded when ctx is done or fn panics.
+func (dr *DependencyRegistry) Call(ctx context.Context, name string, fn func() error, isFailure func(error) bool) error {
+	cb := dr.Breaker(name)
+	if err := cb.Allow(); err != nil {
+		return err
+	}
+	recorded := false
+	defer func() {
+		if !recorded {
+			cb.releaseTrial()
+		}
+	}()
+
+	err := fn()
+	if err != nil && (errors.Is(err, context.Canceled) || ctx.Err() != nil) {
+		// the caller gave up, not the dependency
+		return err
+	}
+	if err != nil && isFailure != nil && !isFailure(err) {
+		cb.Record(nil)
+	} else {
+		cb.Record(err)
+	}
+	recorded = true
+	return err
+}
+
+// Status returns the state of every dependency, sorted by name.
+func (dr *DependencyRegistry) Status() []DependencyStatus {
+	dr.lock.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(dr.breakers))
+	for _, cb := range dr.breakers {
+		breakers = append(breakers, cb)
+	}
+	dr.lock.Unlock()
+
+	statuses := make([]DependencyStatus, 0, len(breakers))
+	for _, cb := range breakers {
+		statuses = append(statuses, cb.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// isUnreachable tells failures of the dependency itself apart from errors
+// in the request, a 4xx answer means the dependency is up.
+func isUnreachable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Retryable()
+	}
+	return true
+}
+
+// FireDependencyRequest is FireRequest guarded by the dependency's circuit breaker.
+func FireDependencyRequest(ctx context.Context, dependency, method, url string, payload []byte, idempotent bool) (*http.Response, error) {
+	var res *http.Response
+	err := GetDependencyRegistry().Call(ctx, dependency, func() error {
+		var err error
+		res, err = FireRequest(ctx, method, url, payload, idempotent)
+		return err
+	}, isUnreachable)
+	return res, err
+}
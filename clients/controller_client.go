@@ -4,17 +4,25 @@ import (
 	"ChintuIdrive/storage-node-watchdog/conf"
 	"ChintuIdrive/storage-node-watchdog/cryption"
 	"ChintuIdrive/storage-node-watchdog/dto"
+	"ChintuIdrive/storage-node-watchdog/fileutil"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
 type ControllerClient struct {
-	controllerConfig *conf.ControllerConfig
+	configLock        sync.RWMutex
+	controllerConfig  *conf.ControllerConfig
+	credentialKey     []byte
+	credentialKeyErr  error
+	credentialKeyOnce sync.Once
 }
 
 func NewControllerClientt(controllerConfig *conf.ControllerConfig) *ControllerClient {
@@ -22,12 +30,26 @@ func NewControllerClientt(controllerConfig *conf.ControllerConfig) *ControllerCl
 		controllerConfig: controllerConfig,
 	}
 }
+
+// SetConfig switches to a reloaded configuration. The credential key is only
+// loaded once, a new credential-key-file needs a restart.
+func (cc *ControllerClient) SetConfig(controllerConfig *conf.ControllerConfig) {
+	cc.configLock.Lock()
+	defer cc.configLock.Unlock()
+	cc.controllerConfig = controllerConfig
+}
+
+func (cc *ControllerClient) getConfig() *conf.ControllerConfig {
+	cc.configLock.RLock()
+	defer cc.configLock.RUnlock()
+	return cc.controllerConfig
+}
+
 func (cc *ControllerClient) GetTenantListFromController() ([]dto.TenatWithProcessInfo, error) {
 	var tenants []dto.TenatWithProcessInfo
 	minioProcessPath := "/opt/e2-node-controller-1/running_processes"
 	dirEntries, err := os.ReadDir(minioProcessPath)
 	if err != nil {
-		fmt.Println(err)
 		return tenants, err
 	}
 
@@ -37,7 +59,7 @@ func (cc *ControllerClient) GetTenantListFromController() ([]dto.TenatWithProces
 				minioProcessFile := filepath.Join(minioProcessPath, entry.Name())
 				file, err := os.Open(minioProcessFile)
 				if err != nil {
-					fmt.Println(err)
+					slog.Warn("Failed to open running process info", "file", minioProcessFile, "error", err)
 					continue
 				}
 				defer file.Close()
@@ -46,12 +68,12 @@ func (cc *ControllerClient) GetTenantListFromController() ([]dto.TenatWithProces
 				decoder := json.NewDecoder(file)
 				err = decoder.Decode(&serverInfo)
 				if err != nil {
-					fmt.Println(err)
+					slog.Warn("Failed to decode running process info", "file", minioProcessFile, "error", err)
 					continue
 				}
 
 				tenants = append(tenants, serverInfo)
-				fmt.Println(minioProcessFile)
+				slog.Debug("Read running process info", "file", minioProcessFile, "tenant", serverInfo.DNS)
 			}
 		}
 
@@ -61,14 +83,15 @@ func (cc *ControllerClient) GetTenantListFromController() ([]dto.TenatWithProces
 
 }
 
-func (cc *ControllerClient) GetAccessKeys(tenat dto.Tenant) (*cryption.SecretData, error) {
+func (cc *ControllerClient) GetAccessKeys(ctx context.Context, tenat dto.Tenant) (*cryption.SecretData, error) {
 
-	url := fmt.Sprintf("https://%s/%s", cc.controllerConfig.ControllerDNS, cc.controllerConfig.AddServiceAccountApi)
+	url := fmt.Sprintf("https://%s/%s", cc.getConfig().ControllerDNS, cc.getConfig().AddServiceAccountApi)
 	method := "POST"
 	// url := "https://localhost:44344/admin/v1/add_service_account"
 	// method := "POST"
+	sac := cc.getConfig().GetServiceAccountConfig()
 	addSrvAcctReq := dto.ServiceAccountReq{
-		Name: "wtachdog",
+		Name: sac.Name,
 		BaseReq: dto.BaseReq{
 			DNS: tenat.DNS,
 			SID: tenat.UserID,
@@ -76,9 +99,14 @@ func (cc *ControllerClient) GetAccessKeys(tenat dto.Tenant) (*cryption.SecretDat
 				CString: tenat.Password.CString,
 			},
 		},
-		IsInternal:    false,
-		IsTestAccount: false,
-		Permissions:   2,
+		IsInternal:           false,
+		IsTestAccount:        false,
+		Permissions:          sac.Permissions,
+		Buckets:              []string{sac.ProbeBucket},
+		ValidTillUtc:         time.Now().UTC().Add(sac.Lifetime.Duration),
+		DisableDeleteBucket:  true,
+		DisableDeleteVersion: true,
+		DisableDeleteObject:  true,
 	}
 
 	payload, err := json.Marshal(addSrvAcctReq)
@@ -86,7 +114,7 @@ func (cc *ControllerClient) GetAccessKeys(tenat dto.Tenant) (*cryption.SecretDat
 		return nil, err
 	}
 
-	res, err := FireRequest(method, url, payload)
+	res, err := FireDependencyRequest(ctx, DependencyController, method, url, payload, false)
 	if err != nil {
 		return nil, err
 	}
@@ -94,14 +122,12 @@ func (cc *ControllerClient) GetAccessKeys(tenat dto.Tenant) (*cryption.SecretDat
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 	var accesskey *cryption.SecretData
 
 	err = json.Unmarshal(body, &accesskey)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	} else {
 		return accesskey, nil
@@ -109,9 +135,19 @@ func (cc *ControllerClient) GetAccessKeys(tenat dto.Tenant) (*cryption.SecretDat
 
 }
 
-func (cc *ControllerClient) GetTenantWithProcessInfo(tenat dto.Tenant) (*dto.TenatWithProcessInfo, error) {
+func (cc *ControllerClient) GetTenantWithProcessInfo(ctx context.Context, tenat dto.Tenant) (*dto.TenatWithProcessInfo, error) {
+	resp, err := cc.GetTenantProcessInfo(ctx, tenat)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.TenatWithProcessInfo, nil
+}
+
+// GetTenantProcessInfo returns the full controller response for the tenant,
+// including the local minio endpoint the tenant process listens on.
+func (cc *ControllerClient) GetTenantProcessInfo(ctx context.Context, tenat dto.Tenant) (*dto.TenantProcessInfoResponse, error) {
 
-	url := fmt.Sprintf("https://%s/%s", cc.controllerConfig.ControllerDNS, cc.controllerConfig.GetTenantInfoApi)
+	url := fmt.Sprintf("https://%s/%s", cc.getConfig().ControllerDNS, cc.getConfig().GetTenantInfoApi)
 	method := "POST"
 
 	clientreq := dto.TenantProcessInfoReq{
@@ -123,20 +159,17 @@ func (cc *ControllerClient) GetTenantWithProcessInfo(tenat dto.Tenant) (*dto.Ten
 	}
 	payload, err := json.Marshal(clientreq)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 
-	res, err := FireRequest(method, url, payload)
+	res, err := FireDependencyRequest(ctx, DependencyController, method, url, payload, true)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 	var resp dto.TenantProcessInfoResponse
@@ -145,70 +178,182 @@ func (cc *ControllerClient) GetTenantWithProcessInfo(tenat dto.Tenant) (*dto.Ten
 		return nil, err
 	}
 
-	return &resp.TenatWithProcessInfo, err
+	return &resp, err
 }
 
-func (cc *ControllerClient) GetSavedAccessKey(tenant dto.Tenant) (*cryption.SecretData, error) {
-	var accessKeys *cryption.SecretData
-	s3credentialsPath := filepath.Join(cc.controllerConfig.AccessKeyDir, tenant.DNS, "s3-credentials.json")
+func (cc *ControllerClient) GetSavedAccessKey(ctx context.Context, tenant dto.Tenant) (*cryption.SecretData, error) {
+	s3credentialsPath := filepath.Join(cc.getConfig().AccessKeyDir, tenant.DNS, "s3-credentials.json")
 	data, err := os.ReadFile(s3credentialsPath)
 	if err != nil {
 		// If the file does not exist, create a default S3Config
-		log.Printf("S3 configuration not available for tenant %s, adding default configuration", tenant.DNS)
-		return cc.LoadS3Credentials(tenant)
-	}
-	err = json.Unmarshal(data, &accessKeys)
-	if err != nil {
-		fmt.Println(err)
-		return accessKeys, err
+		slog.Info("No saved access key, creating one", "tenant", tenant.DNS)
+		return cc.LoadS3Credentials(ctx, tenant)
 	}
-	return accessKeys, nil
+	return cc.openAccessKey(tenant, data)
 }
-func (cc *ControllerClient) LadAccessKeys(tenantsFromApiServer []dto.Tenant) {
+func (cc *ControllerClient) LadAccessKeys(ctx context.Context, tenantsFromApiServer []dto.Tenant) {
 
 	for _, tenant := range tenantsFromApiServer {
-		cc.LoadS3Credentials(tenant)
+		cc.LoadS3Credentials(ctx, tenant)
 	}
 
 }
 
-func (cc *ControllerClient) LoadS3Credentials(tenant dto.Tenant) (*cryption.SecretData, error) {
-	accKey, err := cc.GetAccessKeys(tenant)
+// LoadS3Credentials creates a service account for the probes of the tenant
+// and saves its access key. The account is created scoped to the probe bucket
+// and then gets the policy of the service account config through the tenant's
+// admin API, which the controller can't set. Everything that can fail before
+// the account exists is done first, and an account that can't be restricted
+// is deleted rather than used.
+func (cc *ControllerClient) LoadS3Credentials(ctx context.Context, tenant dto.Tenant) (*cryption.SecretData, error) {
+	sac := cc.getConfig().GetServiceAccountConfig()
+	policy, err := sac.RenderPolicy()
+	if err != nil {
+		return nil, err
+	}
+	processInfo, err := cc.GetTenantProcessInfo(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	adminClient, err := NewTenantAdminClient(tenant, processInfo)
 	if err != nil {
-		log.Printf("Failed to get access keys for tenant %s: %v", tenant.DNS, err)
 		return nil, err
 	}
-	ds, err := accKey.SecretKey.GetDString()
+
+	accKey, err := cc.GetAccessKeys(ctx, tenant)
 	if err != nil {
-		log.Printf("Failed to get DString for tenant %s: %v", tenant.DNS, err)
+		slog.Error("Failed to get access keys", "tenant", tenant.DNS, "error", err)
 		return nil, err
 	}
-	accKey.SecretKey.DString = ds
-	// if err != nil {
-	// 	log.Printf("Failed to set secret key for tenant %s: %v", tenant.DNS, err)
-	// }
-	accKeyDir := filepath.Join(cc.controllerConfig.AccessKeyDir, tenant.DNS)
-	if _, err := os.Stat(accKeyDir); os.IsNotExist(err) {
-		err := os.MkdirAll(cc.controllerConfig.AccessKeyDir, os.ModePerm)
-		if err != nil {
-			log.Printf("Failed to create access key directory: %v", err)
-			return nil, err
+	err = restrictServiceAccount(ctx, adminClient, accKey, policy, sac.Lifetime.Duration)
+	if err == nil {
+		accKey.SecretKey.DString, err = accKey.SecretKey.GetDString()
+	}
+	if err != nil {
+		slog.Error("Failed to set up access key, deleting it", "tenant", tenant.DNS, "access_key", accKey.AccessKey, "error", err)
+		if delErr := adminClient.DeleteServiceAccount(ctx, accKey.AccessKey); delErr != nil {
+			slog.Error("Failed to delete unrestricted access key", "tenant", tenant.DNS, "access_key", accKey.AccessKey, "error", delErr)
 		}
+		return nil, err
+	}
+	err = cc.saveAccessKey(tenant, accKey)
+	if err != nil {
+		return nil, err
+	}
+	return accKey, nil
+}
+
+// restrictServiceAccount attaches the probe policy and expiry to a new
+// service account.
+func restrictServiceAccount(ctx context.Context, adminClient *AdminClient, accKey *cryption.SecretData, policy string, lifetime time.Duration) error {
+	expiration := time.Now().UTC().Add(lifetime)
+	if err := adminClient.UpdateServiceAccount(ctx, accKey.AccessKey, policy, expiration); err != nil {
+		return err
+	}
+	accKey.Expiration = expiration.Format(time.RFC3339)
+	return nil
+}
+
+// sealedAccessKey is the on-disk form of a cached access key. Ciphertext is
+// the JSON encoded cryption.SecretData sealed with the credential key, the
+// tenant DNS is authenticated so a file can't be swapped between tenants.
+type sealedAccessKey struct {
+	Version    int    `json:"version"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+const sealedAccessKeyVersion = 1
+
+func (cc *ControllerClient) getCredentialKey() ([]byte, error) {
+	cc.credentialKeyOnce.Do(func() {
+		cc.credentialKey, cc.credentialKeyErr = cryption.LoadOrCreateKey(cc.getConfig().GetCredentialKeyFile(), "WATCHDOG_CREDENTIAL_KEY")
+	})
+	return cc.credentialKey, cc.credentialKeyErr
+}
+
+func (cc *ControllerClient) saveAccessKey(tenant dto.Tenant, accKey *cryption.SecretData) error {
+	accKeyDir := filepath.Join(cc.getConfig().AccessKeyDir, tenant.DNS)
+	err := os.MkdirAll(accKeyDir, 0700)
+	if err != nil {
+		slog.Error("Failed to create access key directory", "tenant", tenant.DNS, "error", err)
+		return err
+	}
+	// directories created by older versions are world readable
+	err = os.Chmod(accKeyDir, 0700)
+	if err != nil {
+		return err
+	}
+
+	key, err := cc.getCredentialKey()
+	if err != nil {
+		return err
 	}
+	accessKeyData, err := json.Marshal(accKey)
+	if err != nil {
+		slog.Error("Failed to marshal access key data", "tenant", tenant.DNS, "error", err)
+		return err
+	}
+	ciphertext, err := cryption.Seal(key, accessKeyData, []byte(tenant.DNS))
+	if err != nil {
+		return err
+	}
+	sealedData, err := json.MarshalIndent(sealedAccessKey{Version: sealedAccessKeyVersion, Ciphertext: ciphertext}, "", "  ")
+	if err != nil {
+		return err
+	}
+
 	accKeyFilePath := filepath.Join(accKeyDir, "s3-credentials.json")
-	accessKeyFile, err := os.Create(accKeyFilePath)
+	err = fileutil.WriteFileAtomic(accKeyFilePath, sealedData, 0600)
+	if err != nil {
+		slog.Error("Failed to write access key file", "tenant", tenant.DNS, "error", err)
+		return err
+	}
+	return nil
+}
+
+func (cc *ControllerClient) openAccessKey(tenant dto.Tenant, data []byte) (*cryption.SecretData, error) {
+	var sealed sealedAccessKey
+	err := json.Unmarshal(data, &sealed)
 	if err != nil {
-		log.Printf("Failed to create access key file for tenant %s: %v", tenant.DNS, err)
 		return nil, err
 	}
-	defer accessKeyFile.Close()
+	if sealed.Version == 0 {
+		return cc.migrateAccessKey(tenant, data)
+	}
+	if sealed.Version != sealedAccessKeyVersion {
+		return nil, fmt.Errorf("unsupported access key file version %d", sealed.Version)
+	}
 
-	accessKeyData, err := json.MarshalIndent(accKey, "", "  ")
+	key, err := cc.getCredentialKey()
+	if err != nil {
+		return nil, err
+	}
+	accessKeyData, err := cryption.Open(key, sealed.Ciphertext, []byte(tenant.DNS))
+	if err != nil {
+		return nil, fmt.Errorf("cached access key of tenant %s: %v", tenant.DNS, err)
+	}
+	var accessKeys *cryption.SecretData
+	err = json.Unmarshal(accessKeyData, &accessKeys)
 	if err != nil {
-		log.Printf("Failed to marshal access key data for tenant %s: %v", tenant.DNS, err)
 		return nil, err
 	}
+	return accessKeys, nil
+}
 
-	accessKeyFile.Write(accessKeyData)
-	return accKey, nil
+// migrateAccessKey re-saves an access key cached in plain text by an older version.
+func (cc *ControllerClient) migrateAccessKey(tenant dto.Tenant, data []byte) (*cryption.SecretData, error) {
+	var accessKeys *cryption.SecretData
+	err := json.Unmarshal(data, &accessKeys)
+	if err != nil {
+		return nil, err
+	}
+	if accessKeys == nil || accessKeys.AccessKey == "" {
+		return nil, fmt.Errorf("invalid access key file for tenant %s", tenant.DNS)
+	}
+	slog.Info("Encrypting plain text access key file", "tenant", tenant.DNS)
+	err = cc.saveAccessKey(tenant, accessKeys)
+	if err != nil {
+		return nil, err
+	}
+	return accessKeys, nil
 }
@@ -0,0 +1,152 @@
+package clients
+
+import (
+	"ChintuIdrive/storage-node-watchdog/cryption"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// minRotationInterval keeps a tenant whose keys are rejected for other reasons
+// (e.g. a bucket policy) from provisioning a new service account every probe.
+const minRotationInterval = 10 * time.Minute
+
+// CredentialManager hands out the watchdog access key of each tenant and
+// replaces it before it expires or when S3 rejects it. The key of a tenant is
+// provisioned under the tenant's own lock, so a slow controller or tenant
+// does not hold up the others.
+type CredentialManager struct {
+	controllerClient *ControllerClient
+	lock             sync.Mutex // guards tenantLocks and lastRotation
+	tenantLocks      map[string]*sync.Mutex
+	lastRotation     map[string]time.Time
+}
+
+func NewCredentialManager(cc *ControllerClient) *CredentialManager {
+	return &CredentialManager{
+		controllerClient: cc,
+		tenantLocks:      make(map[string]*sync.Mutex),
+		lastRotation:     make(map[string]time.Time),
+	}
+}
+
+// tenantLock returns the lock of the tenant, creating it on first use.
+func (cm *CredentialManager) tenantLock(dns string) *sync.Mutex {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	lock, found := cm.tenantLocks[dns]
+	if !found {
+		lock = &sync.Mutex{}
+		cm.tenantLocks[dns] = lock
+	}
+	return lock
+}
+
+func (cm *CredentialManager) getLastRotation(dns string) time.Time {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	return cm.lastRotation[dns]
+}
+
+func (cm *CredentialManager) setLastRotation(dns string, at time.Time) {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+	cm.lastRotation[dns] = at
+}
+
+// GetAccessKey returns the cached access key of the tenant with the secret
+// decrypted, provisioning a new one if there is none or it is about to expire.
+func (cm *CredentialManager) GetAccessKey(ctx context.Context, tenant dto.Tenant) (*cryption.SecretData, error) {
+	lock := cm.tenantLock(tenant.DNS)
+	lock.Lock()
+	defer lock.Unlock()
+
+	accKey, err := cm.controllerClient.GetSavedAccessKey(ctx, tenant)
+	if err != nil {
+		slog.Warn("Failed to get saved access key", "tenant", tenant.DNS, "error", err)
+		return cm.rotate(ctx, tenant, nil)
+	}
+
+	expiration, err := accKey.ExpiresAt()
+	if err != nil {
+		slog.Warn("Saved access key is unusable, replacing it", "tenant", tenant.DNS, "error", err)
+		return cm.rotate(ctx, tenant, accKey)
+	}
+	if !expiration.IsZero() && time.Until(expiration) < cm.controllerClient.getConfig().AccessKeyRefreshWindow.Duration {
+		slog.Info("Access key expires soon, rotating it", "tenant", tenant.DNS, "expiration", expiration)
+		return cm.rotate(ctx, tenant, accKey)
+	}
+
+	if accKey.SecretKey.DString == "" {
+		ds, err := accKey.SecretKey.GetDString()
+		if err != nil {
+			return nil, err
+		}
+		accKey.SecretKey.DString = ds
+	}
+	return accKey, nil
+}
+
+// RotateAccessKey replaces the access key S3 rejected with a new one.
+func (cm *CredentialManager) RotateAccessKey(ctx context.Context, tenant dto.Tenant, rejected *cryption.SecretData) (*cryption.SecretData, error) {
+	lock := cm.tenantLock(tenant.DNS)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if time.Since(cm.getLastRotation(tenant.DNS)) < minRotationInterval {
+		return nil, fmt.Errorf("access key of tenant %s was rotated less than %v ago", tenant.DNS, minRotationInterval)
+	}
+	slog.Warn("Access key was rejected, rotating it", "tenant", tenant.DNS, "access_key", rejected.AccessKey)
+	return cm.rotate(ctx, tenant, rejected)
+}
+
+// rotate provisions and saves a new access key, then removes the service
+// account of the superseded one. It must be called with the tenant's lock held.
+func (cm *CredentialManager) rotate(ctx context.Context, tenant dto.Tenant, superseded *cryption.SecretData) (*cryption.SecretData, error) {
+	accKey, err := cm.controllerClient.LoadS3Credentials(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	cm.setLastRotation(tenant.DNS, time.Now())
+
+	if superseded == nil || superseded.AccessKey == "" || superseded.AccessKey == accKey.AccessKey {
+		return accKey, nil
+	}
+	if err := cm.deleteServiceAccount(ctx, tenant, superseded.AccessKey); err != nil {
+		// the new key works, a leftover service account only needs cleaning up
+		slog.Warn("Failed to delete superseded access key", "tenant", tenant.DNS, "access_key", superseded.AccessKey, "error", err)
+	}
+	return accKey, nil
+}
+
+func (cm *CredentialManager) deleteServiceAccount(ctx context.Context, tenant dto.Tenant, accessKey string) error {
+	processInfo, err := cm.controllerClient.GetTenantProcessInfo(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	adminClient, err := NewTenantAdminClient(tenant, processInfo)
+	if err != nil {
+		return err
+	}
+	return adminClient.DeleteServiceAccount(ctx, accessKey)
+}
+
+// IsInvalidAccessKeyError reports whether S3 rejected the request because of
+// the access key, meaning it has expired or was revoked. Other 403s such as
+// AccessDenied come from the policy of a valid key and are not matched.
+func IsInvalidAccessKeyError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "InvalidAccessKeyId", "SignatureDoesNotMatch", "ExpiredToken", "InvalidToken":
+			return true
+		}
+	}
+	return false
+}
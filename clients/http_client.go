@@ -0,0 +1,141 @@
+package clients
+
+import (
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPStatusError is returned by FireRequest when the server answers with a
+// status outside 2xx. Body holds the start of the response body.
+type HTTPStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the request may succeed if sent again.
+func (e *HTTPStatusError) Retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// maxErrorBodySize limits how much of an error response ends up in the error
+const maxErrorBodySize = 512
+
+var (
+	httpClientConfig = conf.HTTPClientConfig{}
+	httpClient       *http.Client
+	httpClientLock   sync.RWMutex
+)
+
+// ConfigureHTTPClient sets up the client shared by every FireRequest call.
+func ConfigureHTTPClient(hcc conf.HTTPClientConfig) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = hcc.MaxIdleConns
+	transport.MaxIdleConnsPerHost = hcc.MaxIdleConns
+	transport.IdleConnTimeout = hcc.IdleConnTimeout.Duration
+
+	httpClientLock.Lock()
+	defer httpClientLock.Unlock()
+	httpClientConfig = hcc
+	httpClient = &http.Client{
+		Transport: transport,
+		Timeout:   hcc.Timeout.Duration,
+	}
+}
+
+func getHTTPClient() (*http.Client, conf.HTTPClientConfig) {
+	httpClientLock.RLock()
+	client, hcc := httpClient, httpClientConfig
+	httpClientLock.RUnlock()
+	if client != nil {
+		return client, hcc
+	}
+	ConfigureHTTPClient((&conf.Config{}).GetHTTPClientConfig())
+	return getHTTPClient()
+}
+
+// FireRequest sends a JSON request and returns the response if its status is
+// 2xx, the caller must close the body. Idempotent requests are retried with
+// jittered exponential backoff on network errors, 5xx and 429 responses.
+func FireRequest(ctx context.Context, method, url string, payload []byte, idempotent bool) (*http.Response, error) {
+	client, hcc := getHTTPClient()
+	maxRetries := hcc.MaxRetries
+	if !idempotent || maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := backoff(hcc, attempt)
+			slog.Warn("Retrying request", "method", method, "url", url, "delay", delay, "error", lastErr)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		res, err := fireRequestOnce(ctx, client, method, url, payload)
+		if err == nil {
+			return res, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) && !statusErr.Retryable() {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+func fireRequestOnce(ctx context.Context, client *http.Client, method, url string, payload []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+		return nil, &HTTPStatusError{Method: method, URL: url, StatusCode: res.StatusCode, Body: string(body)}
+	}
+	return res, nil
+}
+
+// backoff returns the delay before the given retry: exponential growth from
+// InitialBackoff capped at MaxBackoff, with jitter over its upper half so
+// watchdogs on many nodes don't retry in lockstep.
+func backoff(hcc conf.HTTPClientConfig, attempt int) time.Duration {
+	delay := hcc.InitialBackoff.Duration
+	for i := 1; i < attempt && delay < hcc.MaxBackoff.Duration; i++ {
+		delay *= 2
+	}
+	if delay > hcc.MaxBackoff.Duration {
+		delay = hcc.MaxBackoff.Duration
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -2,7 +2,7 @@ package clients
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -18,13 +18,13 @@ type IAMClient struct {
 func NewIAMClient(endpoint, accKey, secKey string) *IAMClient {
 	err := os.Setenv("AWS_ACCESS_KEY_ID", accKey)
 	if err != nil {
-		log.Print("unable to set S3 ENV AWS_ACCESS_KEY_ID")
+		slog.Error("Failed to set AWS_ACCESS_KEY_ID", "error", err)
 		return nil
 	}
 
 	err = os.Setenv("AWS_SECRET_ACCESS_KEY", secKey)
 	if err != nil {
-		log.Print("unable to set ENV AWS_SECRET_ACCESS_KEY")
+		slog.Error("Failed to set AWS_SECRET_ACCESS_KEY", "error", err)
 		return nil
 	}
 
@@ -33,7 +33,7 @@ func NewIAMClient(endpoint, accKey, secKey string) *IAMClient {
 		config.WithRegion(endpoints.UsEast1RegionID),
 	)
 	if err != nil {
-		log.Print(err)
+		slog.Error("Failed to load AWS config", "error", err)
 	}
 
 	client := iam.NewFromConfig(cfg, func(o *iam.Options) {
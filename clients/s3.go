@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"os"
 	"sort"
@@ -13,6 +13,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
@@ -24,76 +25,54 @@ type S3Client struct {
 	client *s3.Client
 }
 
-func NewS3Client(endpoint, accKey, secKey string) *S3Client {
-	log.Print("exporting user S3 credentials")
-	err := os.Setenv("AWS_ACCESS_KEY_ID", accKey)
-	if err != nil {
-		log.Print("unable to set S3 ENV AWS_ACCESS_KEY_ID")
-		return &S3Client{}
-	}
-
-	err = os.Setenv("AWS_SECRET_ACCESS_KEY", secKey)
-	if err != nil {
-		log.Print("unable to set ENV AWS_SECRET_ACCESS_KEY")
-		return &S3Client{}
-	}
-
-	cfg, err := config.LoadDefaultConfig(
-		context.TODO(),
-		config.WithRegion(endpoints.UsEast1RegionID),
-	)
-	if err != nil {
-		log.Print(err)
-	}
-
-	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String("https://" + endpoint)
-	})
-
-	return &S3Client{client: client}
+func NewS3Client(endpoint, accKey, secKey string) (*S3Client, error) {
+	return newS3Client("https://"+endpoint, accKey, secKey, false)
 }
 
-func NewS3ClientHttp(endpoint, accKey, secKey string) *S3Client {
-	log.Print("exporting user S3 credentials", accKey, secKey)
-	err := os.Setenv("AWS_ACCESS_KEY_ID", accKey)
-	if err != nil {
-		log.Print("unable to set S3 ENV AWS_ACCESS_KEY_ID")
-		return &S3Client{}
-	}
+func NewS3ClientHttp(endpoint, accKey, secKey string) (*S3Client, error) {
+	return newS3Client("http://"+endpoint, accKey, secKey, false)
+}
 
-	err = os.Setenv("AWS_SECRET_ACCESS_KEY", secKey)
-	if err != nil {
-		log.Print("unable to set ENV AWS_SECRET_ACCESS_KEY")
-		return &S3Client{}
+// NewLocalS3Client creates a client for a tenant's minio process on this node.
+// Path style addressing is used since buckets can't be resolved as
+// subdomains of a local host:port.
+func NewLocalS3Client(endpoint, accKey, secKey string, useHttp bool) (*S3Client, error) {
+	scheme := "https://"
+	if useHttp {
+		scheme = "http://"
 	}
+	return newS3Client(scheme+endpoint, accKey, secKey, true)
+}
 
+// newS3Client gives the client its own credentials, clients of different
+// tenants are created at the same time.
+func newS3Client(baseEndpoint, accKey, secKey string, usePathStyle bool) (*S3Client, error) {
 	cfg, err := config.LoadDefaultConfig(
 		context.TODO(),
 		config.WithRegion(endpoints.UsEast1RegionID),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accKey, secKey, "")),
 	)
 	if err != nil {
-		log.Print(err)
+		return nil, fmt.Errorf("failed to load AWS config for %s: %w", baseEndpoint, err)
 	}
 
 	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		o.BaseEndpoint = aws.String("http://" + endpoint)
+		o.BaseEndpoint = aws.String(baseEndpoint)
+		o.UsePathStyle = usePathStyle
 	})
 
-	return &S3Client{client: client}
+	return &S3Client{client: client}, nil
 }
 
-func (s *S3Client) ListBuckets() ([]types.Bucket, error) {
+func (s *S3Client) ListBuckets(ctx context.Context) ([]types.Bucket, error) {
 	var buckets []types.Bucket
-	ctx := context.TODO()
-	// ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
-	// defer cancel()
 	result, err := s.client.ListBuckets(ctx, &s3.ListBucketsInput{})
 	if err != nil {
-		log.Printf("Couldn't list buckets for your account. Here's why: %v", err)
+		slog.Warn("Failed to list buckets", "error", err)
 		return buckets, err
 	}
 	if len(result.Buckets) == 0 {
-		log.Print("You don't have any buckets!")
+		slog.Debug("Account has no buckets")
 	} else {
 		buckets = result.Buckets
 		sort.Slice(buckets, func(i, j int) bool {
@@ -113,7 +92,7 @@ func (client *S3Client) ObjectsCountForBucket(bucketName string) (int, error) {
 	}
 	return len(result.Contents), nil
 }
-func (s *S3Client) ListObjectsForBucket(bucket string, numOfPagesToList int) (int, error) {
+func (s *S3Client) ListObjectsForBucket(ctx context.Context, bucket string, numOfPagesToList int) (int, error) {
 	if bucket == "" {
 		return 0, nil
 	}
@@ -139,13 +118,13 @@ func (s *S3Client) ListObjectsForBucket(bucket string, numOfPagesToList int) (in
 	var objectCount int
 	var err error
 	if numOfPagesToList >= 1 {
-		objectCount, err = getObjCount(paginator, numOfPagesToList)
+		objectCount, err = getObjCount(ctx, paginator, numOfPagesToList)
 	} else {
-		objectCount, err = getAllObjCount(paginator)
+		objectCount, err = getAllObjCount(ctx, paginator)
 	}
 	return objectCount, err
 }
-func getObjCount(paginator *s3.ListObjectsV2Paginator, numOfPagesToList int) (int, error) {
+func getObjCount(ctx context.Context, paginator *s3.ListObjectsV2Paginator, numOfPagesToList int) (int, error) {
 	var pagesCount int
 	var objectCount int
 	for paginator.HasMorePages() && (pagesCount < numOfPagesToList) {
@@ -153,7 +132,7 @@ func getObjCount(paginator *s3.ListObjectsV2Paginator, numOfPagesToList int) (in
 
 		// Next Page takes a new context for each page retrieval. This is where
 		// you could add timeouts or deadlines.
-		page, err := paginator.NextPage(context.TODO())
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
 			return objectCount, err
 		}
@@ -163,16 +142,15 @@ func getObjCount(paginator *s3.ListObjectsV2Paginator, numOfPagesToList int) (in
 		// 	fmt.Println("Object:", *obj.Key)
 		// }
 		objectCount = objectCount + len(page.Contents)
-		log.Printf("page no:%d objects-count:%d", pagesCount, objectCount)
+		slog.Debug("Listed objects page", "page", pagesCount, "objects", objectCount)
 		//fmt.Println("Num objects:", len(page.Contents))
 	}
 
-	log.Println("total pages:", pagesCount)
-	log.Println("total objectsCount:", objectCount)
+	slog.Debug("Listed objects", "pages", pagesCount, "objects", objectCount)
 
 	return objectCount, nil
 }
-func getAllObjCount(paginator *s3.ListObjectsV2Paginator) (int, error) {
+func getAllObjCount(ctx context.Context, paginator *s3.ListObjectsV2Paginator) (int, error) {
 	var pagesCount int
 	var objectCount int
 	for paginator.HasMorePages() {
@@ -180,7 +158,7 @@ func getAllObjCount(paginator *s3.ListObjectsV2Paginator) (int, error) {
 
 		// Next Page takes a new context for each page retrieval. This is where
 		// you could add timeouts or deadlines.
-		page, err := paginator.NextPage(context.TODO())
+		page, err := paginator.NextPage(ctx)
 		if err != nil {
 			return objectCount, err
 		}
@@ -190,11 +168,10 @@ func getAllObjCount(paginator *s3.ListObjectsV2Paginator) (int, error) {
 		// 	fmt.Println("Object:", *obj.Key)
 		// }
 		objectCount = objectCount + len(page.Contents)
-		log.Printf("page no:%d objects-count:%d", pagesCount, objectCount)
+		slog.Debug("Listed objects page", "page", pagesCount, "objects", objectCount)
 		//fmt.Println("Num objects:", len(page.Contents))
 	}
-	log.Println("total pages:", pagesCount)
-	log.Println("total objectsCount:", objectCount)
+	slog.Debug("Listed objects", "pages", pagesCount, "objects", objectCount)
 	return objectCount, nil
 }
 
@@ -259,7 +236,7 @@ func (s *S3Client) PutObjects(bucketName string, numObjects int, objectGenerator
 
 		if i%200 == 0 {
 			wg.Wait()
-			fmt.Println("Done:", i)
+			slog.Debug("Uploaded objects", "bucket", bucketName, "count", i)
 		}
 
 		wg.Add(1)
@@ -270,7 +247,7 @@ func (s *S3Client) PutObjects(bucketName string, numObjects int, objectGenerator
 				lock.Lock()
 				defer lock.Unlock()
 				errState = err
-				log.Println("Error while uploading object:" + objName + ", error:" + err.Error())
+				slog.Error("Failed to upload object", "bucket", bucketName, "object", objName, "error", err)
 			}
 		}()
 	}
@@ -279,7 +256,7 @@ func (s *S3Client) PutObjects(bucketName string, numObjects int, objectGenerator
 }
 
 func (s *S3Client) CreateBucket(bucketName string, enableLocking bool) error {
-	fmt.Println("Creating bucket:", bucketName)
+	slog.Debug("Creating bucket", "bucket", bucketName)
 
 	input := &s3.CreateBucketInput{
 		Bucket:                     aws.String(bucketName),
@@ -347,9 +324,6 @@ func (s *S3Client) PutBucketLogging(sourceBucket, targetBucket, targetPrefix str
 		param.BucketLoggingStatus.LoggingEnabled.TargetObjectKeyFormat = tokf
 	}
 	_, err := s.client.PutBucketLogging(context.TODO(), &param)
-	if err != nil {
-		fmt.Println("Error in PutBucketLogging:", err)
-	}
 	return err
 }
 
@@ -358,9 +332,6 @@ func (s *S3Client) PutBucketLoggingDisable(sourceBucket string) error {
 	param.Bucket = aws.String(sourceBucket)
 	param.BucketLoggingStatus = &types.BucketLoggingStatus{}
 	_, err := s.client.PutBucketLogging(context.TODO(), &param)
-	if err != nil {
-		fmt.Println("Error in PutBucketLogging:", err)
-	}
 	return err
 }
 
@@ -372,7 +343,7 @@ func (s *S3Client) PutNestedObject(bucketName string, objName string) {
 
 	_, err := s.client.PutObject(context.TODO(), input)
 	if err != nil {
-		log.Println("Error while uploading object:" + objName + ", error:" + err.Error())
+		slog.Error("Failed to upload object", "bucket", bucketName, "object", objName, "error", err)
 	}
 }
 
@@ -609,7 +580,7 @@ func (s *S3Client) MultipartUpload(bucket, object, filePath string) error {
 		return err
 	}
 	uploadID := *output.UploadId
-	fmt.Println("upload id:", uploadID)
+	slog.Debug("Created multipart upload", "bucket", bucket, "object", object, "upload_id", uploadID)
 
 	fp, err := os.Open(object)
 	if err != nil {
@@ -620,7 +591,7 @@ func (s *S3Client) MultipartUpload(bucket, object, filePath string) error {
 	chunkSize := int64(5244955)
 	//chunkSize := int64(5 * 1024 * 1024)
 	totalParts := math.Ceil(float64(fileSize) / float64(chunkSize))
-	fmt.Println("totalParts:", totalParts)
+	slog.Debug("Uploading parts", "upload_id", uploadID, "parts", totalParts)
 	partNum := 0
 	parts := types.CompletedMultipartUpload{}
 	parts.Parts = []types.CompletedPart{}
@@ -637,7 +608,6 @@ func (s *S3Client) MultipartUpload(bucket, object, filePath string) error {
 
 		//tempFile.Write(fileChunk[:n])
 		partNum++
-		fmt.Println("partNum:", partNum, "Size:", n)
 		upInput := &s3.UploadPartInput{}
 		upInput.Bucket = aws.String(bucket)
 		upInput.Key = aws.String(object)
@@ -650,11 +620,9 @@ func (s *S3Client) MultipartUpload(bucket, object, filePath string) error {
 		upOutput, err := s.client.UploadPart(context.TODO(), upInput)
 		_ = upOutput
 		if err != nil {
-			fmt.Println("Failed to upload part:", partNum, "error:", err)
-			return err
-		} else {
-			fmt.Println("Successfully uploaded part:", partNum)
+			return fmt.Errorf("failed to upload part %d: %w", partNum, err)
 		}
+		slog.Debug("Uploaded part", "upload_id", uploadID, "part", partNum, "size", n)
 		cpPart := types.CompletedPart{}
 		cpPart.ETag = upOutput.ETag
 		{
@@ -673,7 +641,6 @@ func (s *S3Client) MultipartUpload(bucket, object, filePath string) error {
 
 	_, err = s.client.CompleteMultipartUpload(context.TODO(), cpInput)
 	if err != nil {
-		fmt.Println("Error in CompleteMultipartUpload, err:", err)
 		return err
 	}
 
@@ -0,0 +1,151 @@
+package clients
+
+import (
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"ChintuIdrive/storage-node-watchdog/fileutil"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Where the tenant list handed to the monitors came from
+const (
+	TenantListFromAPIServer          = "api-server"
+	TenantListFromCache              = "cache"
+	TenantListFromCacheAndController = "cache+controller"
+	TenantListFromController         = "controller"
+)
+
+// TenantListStatus describes the tenant list last handed out.
+type TenantListStatus struct {
+	Source    string        `json:"source"`
+	FetchedAt time.Time     `json:"fetched_at"` // when the API server returned the list
+	Age       time.Duration `json:"age"`        // how stale the list is, zero when fresh
+	Tenants   int           `json:"tenants"`
+	LastError string        `json:"last_error,omitempty"` // why the API server could not be used
+}
+
+type cachedTenantList struct {
+	FetchedAt time.Time      `json:"fetched_at"`
+	NodeInfo  dto.TenantList `json:"node_info"`
+}
+
+// TenantListCache keeps the last tenant list the API server returned in
+// memory and on disk, so that it survives a restart of the watchdog.
+type TenantListCache struct {
+	path   string
+	lock   sync.Mutex
+	cached *cachedTenantList
+	dirty  bool // cached could not be written to disk
+}
+
+func NewTenantListCache(path string) *TenantListCache {
+	return &TenantListCache{
+		path: path,
+	}
+}
+
+// Save replaces the cached tenant list. The list holds the encrypted tenant
+// passwords, so the file is only readable by the watchdog.
+func (tlc *TenantListCache) Save(nodeInfo *dto.TenantList, fetchedAt time.Time) error {
+	cached := &cachedTenantList{FetchedAt: fetchedAt, NodeInfo: *nodeInfo}
+
+	tlc.lock.Lock()
+	defer tlc.lock.Unlock()
+	tlc.cached = cached
+	return tlc.write()
+}
+
+// Flush writes the cached tenant list again if the last Save could not.
+func (tlc *TenantListCache) Flush() error {
+	tlc.lock.Lock()
+	defer tlc.lock.Unlock()
+	if !tlc.dirty {
+		return nil
+	}
+	return tlc.write()
+}
+
+// write must be called with the lock held.
+func (tlc *TenantListCache) write() error {
+	tlc.dirty = true
+	data, err := json.Marshal(tlc.cached)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(tlc.path), 0700); err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(tlc.path, data, 0600); err != nil {
+		return err
+	}
+	tlc.dirty = false
+	return nil
+}
+
+// Load returns the cached tenant list and when it was fetched, reading the
+// file written by a previous run if nothing was saved since start.
+func (tlc *TenantListCache) Load() (*dto.TenantList, time.Time, error) {
+	tlc.lock.Lock()
+	defer tlc.lock.Unlock()
+
+	if tlc.cached == nil {
+		data, err := os.ReadFile(tlc.path)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("no cached tenant list: %w", err)
+		}
+		var cached cachedTenantList
+		if err := json.Unmarshal(data, &cached); err != nil {
+			return nil, time.Time{}, fmt.Errorf("invalid cached tenant list %s: %w", tlc.path, err)
+		}
+		tlc.cached = &cached
+	}
+	nodeInfo := tlc.cached.NodeInfo
+	nodeInfo.TenantList = append([]dto.Tenant{}, tlc.cached.NodeInfo.TenantList...)
+	return &nodeInfo, tlc.cached.FetchedAt, nil
+}
+
+// mergeRunningTenants adds the tenants running on this node that the tenant
+// list doesn't know about, with the settings the controller runs them with.
+func mergeRunningTenants(tenants []dto.Tenant, running []dto.TenatWithProcessInfo) []dto.Tenant {
+	known := make(map[string]bool, len(tenants))
+	for _, tenant := range tenants {
+		known[tenant.DNS] = true
+	}
+	for _, processInfo := range running {
+		if processInfo.DNS == "" || known[processInfo.DNS] {
+			continue
+		}
+		known[processInfo.DNS] = true
+		tenants = append(tenants, tenantFromProcessInfo(processInfo))
+	}
+	return tenants
+}
+
+func tenantFromProcessInfo(processInfo dto.TenatWithProcessInfo) dto.Tenant {
+	cnameList := make([]interface{}, 0, len(processInfo.CNameList))
+	for _, cname := range processInfo.CNameList {
+		cnameList = append(cnameList, cname)
+	}
+	return dto.Tenant{
+		DNS:                       processInfo.DNS,
+		UserID:                    processInfo.UserID,
+		Password:                  processInfo.Password,
+		E2UserID:                  processInfo.E2UserID,
+		PublicBucketsEnabled:      processInfo.PublicBucketsEnabled,
+		EnablePublicAccessOnE2URL: processInfo.EnablePublicAccessOnE2URL,
+		CnameList:                 cnameList,
+		AllowedOrigin:             processInfo.AllowedOrigin,
+		Compression:               processInfo.Compression,
+		MaxAPIRequests:            processInfo.MaxApiRequests,
+		APIRequestsDeadline:       processInfo.ApiRequestsDeadline,
+		Whitelist:                 processInfo.Whitelist,
+		Blacklist:                 processInfo.Blacklist,
+		UseDEC:                    processInfo.UseDEC,
+		DownloadLimit:             processInfo.DownloadLimit,
+		UploadLimit:               processInfo.UploadLimit,
+	}
+}
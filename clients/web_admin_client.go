@@ -1,7 +1,7 @@
 package clients
 
 import (
-	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"path/filepath"
@@ -17,14 +17,13 @@ func NewWebAdminClient(accessKey, secretKey, url string) *WebAdminClient {
 	cred := Credentials{}
 	cred.AccessKeyID = accessKey
 	cred.SecretAccessKey = secretKey
-	fmt.Println("wac url:", url)
+	slog.Debug("Created web admin client", "url", url)
 	return &WebAdminClient{creds: cred, url: url}
 }
 
 func (w *WebAdminClient) SendMessage(path, payload string) (*http.Response, error) {
 	bodyReader := strings.NewReader(payload)
 
-	fmt.Println("url is:", w.url)
 	queryPath := filepath.Join("minio/admin/v3", path)
 	url := w.url + "/" + queryPath
 
@@ -36,7 +35,7 @@ func (w *WebAdminClient) SendMessage(path, payload string) (*http.Response, erro
 	{
 		res, err := httputil.DumpRequest(req, true)
 		if err == nil {
-			fmt.Println("Signed Request is:", string(res))
+			slog.Debug("Sending signed request", "request", string(res))
 		}
 	}
 
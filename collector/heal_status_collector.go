@@ -0,0 +1,77 @@
+package collector
+
+import (
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
+	"time"
+)
+
+type TenantHealStatus struct {
+	DNS               string        `json:"dns"`
+	Healing           bool          `json:"healing"`
+	HealingDrives     []string      `json:"healing_drives"`
+	ActiveHealWorkers int           `json:"active_heal_workers"` // one per drive still healing
+	OfflineEndpoints  []string      `json:"offline_endpoints"`
+	ScannedItemsCount int64         `json:"scanned_items_count"`
+	ItemsHealed       uint64        `json:"items_healed"`
+	ItemsFailed       uint64        `json:"items_failed"`
+	BytesDone         uint64        `json:"bytes_done"`
+	BytesFailed       uint64        `json:"bytes_failed"`
+	Started           time.Time     `json:"started"`
+	Duration          time.Duration `json:"duration"`
+}
+
+type HealStatusCollector struct {
+	controllerClient *clients.ControllerClient
+}
+
+func NewHealStatusCollector(cc *clients.ControllerClient) *HealStatusCollector {
+	return &HealStatusCollector{
+		controllerClient: cc,
+	}
+}
+
+func (hsc *HealStatusCollector) CollectHealStatus(ctx context.Context, tenant dto.Tenant) (*TenantHealStatus, error) {
+	processInfo, err := hsc.controllerClient.GetTenantProcessInfo(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	adminClient, err := clients.NewTenantAdminClient(tenant, processInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	healState, err := adminClient.BackgroundHealStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	healStatus := &TenantHealStatus{
+		DNS:               tenant.DNS,
+		Healing:           len(healState.HealDisks) > 0,
+		HealingDrives:     healState.HealDisks,
+		OfflineEndpoints:  healState.OfflineEndpoints,
+		ScannedItemsCount: healState.ScannedItemsCount,
+	}
+	for _, set := range healState.Sets {
+		for _, disk := range set.Disks {
+			healInfo := disk.HealInfo
+			if healInfo == nil || healInfo.Finished {
+				continue
+			}
+			healStatus.ActiveHealWorkers++
+			healStatus.ItemsHealed += healInfo.ItemsHealed
+			healStatus.ItemsFailed += healInfo.ItemsFailed
+			healStatus.BytesDone += healInfo.BytesDone
+			healStatus.BytesFailed += healInfo.BytesFailed
+			if healStatus.Started.IsZero() || healInfo.Started.Before(healStatus.Started) {
+				healStatus.Started = healInfo.Started
+			}
+		}
+	}
+	if !healStatus.Started.IsZero() {
+		healStatus.Duration = time.Since(healStatus.Started)
+	}
+
+	return healStatus, nil
+}
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/minio/madmin-go/v3"
+)
+
+type MinioHealth struct {
+	DNS           string              `json:"dns"`
+	Mode          string              `json:"mode"`
+	DeploymentID  string              `json:"deployment_id"`
+	Servers       []MinioServerHealth `json:"servers"`
+	Drives        []DriveHealth       `json:"drives"`
+	ErasureSets   []ErasureSetHealth  `json:"erasure_sets"`
+	OnlineDrives  int                 `json:"online_drives"`
+	OfflineDrives int                 `json:"offline_drives"`
+	FaultyDrives  int                 `json:"faulty_drives"`
+	HealingDrives int                 `json:"healing_drives"`
+}
+
+type MinioServerHealth struct {
+	Endpoint string        `json:"endpoint"`
+	State    string        `json:"state"`
+	Version  string        `json:"version"`
+	Uptime   time.Duration `json:"uptime"`
+}
+
+type DriveHealth struct {
+	Endpoint   string `json:"endpoint"`
+	Path       string `json:"path"`
+	State      string `json:"state"`
+	Healing    bool   `json:"healing"`
+	PoolIndex  int    `json:"pool_index"`
+	SetIndex   int    `json:"set_index"`
+	TotalSpace uint64 `json:"total_space"`
+	UsedSpace  uint64 `json:"used_space"`
+}
+
+type ErasureSetHealth struct {
+	PoolIndex     int  `json:"pool_index"`
+	SetIndex      int  `json:"set_index"`
+	OnlineDrives  int  `json:"online_drives"`
+	OfflineDrives int  `json:"offline_drives"`
+	Parity        int  `json:"parity"`
+	Healthy       bool `json:"healthy"` // enough drives online to serve reads and writes
+}
+
+type MinioHealthCollector struct {
+	controllerClient *clients.ControllerClient
+}
+
+func NewMinioHealthCollector(cc *clients.ControllerClient) *MinioHealthCollector {
+	return &MinioHealthCollector{
+		controllerClient: cc,
+	}
+}
+
+func (mhc *MinioHealthCollector) CollectMinioHealth(ctx context.Context, tenant dto.Tenant) (*MinioHealth, error) {
+	processInfo, err := mhc.controllerClient.GetTenantProcessInfo(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	adminClient, err := clients.NewTenantAdminClient(tenant, processInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	serverInfo, err := adminClient.ServerInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	minioHealth := &MinioHealth{
+		DNS:          tenant.DNS,
+		Mode:         serverInfo.Mode,
+		DeploymentID: serverInfo.DeploymentID,
+	}
+	var disks []madmin.Disk
+	for _, server := range serverInfo.Servers {
+		minioHealth.Servers = append(minioHealth.Servers, MinioServerHealth{
+			Endpoint: server.Endpoint,
+			State:    server.State,
+			Version:  server.Version,
+			Uptime:   time.Duration(server.Uptime) * time.Second,
+		})
+		disks = append(disks, server.Disks...)
+	}
+
+	// server info leaves out drives of unreachable servers, storage info does not
+	storageInfo, err := adminClient.StorageInfo(ctx)
+	if err == nil && len(storageInfo.Disks) > 0 {
+		disks = storageInfo.Disks
+	}
+
+	parity := serverInfo.Backend.StandardSCParity
+	setMap := make(map[[2]int]*ErasureSetHealth)
+	for _, disk := range disks {
+		minioHealth.Drives = append(minioHealth.Drives, DriveHealth{
+			Endpoint:   disk.Endpoint,
+			Path:       disk.DrivePath,
+			State:      disk.State,
+			Healing:    disk.Healing,
+			PoolIndex:  disk.PoolIndex,
+			SetIndex:   disk.SetIndex,
+			TotalSpace: disk.TotalSpace,
+			UsedSpace:  disk.UsedSpace,
+		})
+		key := [2]int{disk.PoolIndex, disk.SetIndex}
+		set, found := setMap[key]
+		if !found {
+			set = &ErasureSetHealth{PoolIndex: disk.PoolIndex, SetIndex: disk.SetIndex, Parity: parity}
+			setMap[key] = set
+		}
+		switch disk.State {
+		case madmin.DriveStateOk:
+			minioHealth.OnlineDrives++
+			set.OnlineDrives++
+		case madmin.DriveStateOffline, madmin.DriveStateMissing:
+			minioHealth.OfflineDrives++
+			set.OfflineDrives++
+		default:
+			minioHealth.FaultyDrives++
+			set.OfflineDrives++
+		}
+		if disk.Healing {
+			minioHealth.HealingDrives++
+		}
+	}
+	for _, set := range setMap {
+		set.Healthy = set.OfflineDrives <= set.Parity
+		minioHealth.ErasureSets = append(minioHealth.ErasureSets, *set)
+	}
+	sort.Slice(minioHealth.ErasureSets, func(i, j int) bool {
+		if minioHealth.ErasureSets[i].PoolIndex != minioHealth.ErasureSets[j].PoolIndex {
+			return minioHealth.ErasureSets[i].PoolIndex < minioHealth.ErasureSets[j].PoolIndex
+		}
+		return minioHealth.ErasureSets[i].SetIndex < minioHealth.ErasureSets[j].SetIndex
+	})
+
+	return minioHealth, nil
+}
@@ -22,14 +22,14 @@ type TenantProcessMetrics struct {
 }
 
 type ProcesMetricsCollector struct {
-	config *conf.Config
+	configStore *conf.ConfigStore
 	//processStats      []ProcessMetrics
 	//tenatProcessStats []TenantProcessMetrics
 }
 
-func NewProcesMetricsCollector(config *conf.Config) *ProcesMetricsCollector {
+func NewProcesMetricsCollector(configStore *conf.ConfigStore) *ProcesMetricsCollector {
 	return &ProcesMetricsCollector{
-		config: config,
+		configStore: configStore,
 	}
 }
 
@@ -38,7 +38,7 @@ func NewProcesMetricsCollector(config *conf.Config) *ProcesMetricsCollector {
 
 // Collect per-process metrics
 func (pmc *ProcesMetricsCollector) CollectProcessMetrics() []ProcessMetrics {
-	monitoredProcesses := pmc.config.GetProcessToMonitor()
+	monitoredProcesses := pmc.configStore.Get().GetProcessToMonitor()
 	//for {
 	processList, _ := process.Processes()
 	var metrics []ProcessMetrics
@@ -78,7 +78,7 @@ func (pmc *ProcesMetricsCollector) CollectProcessMetrics() []ProcessMetrics {
 // }
 
 func (pmc *ProcesMetricsCollector) CollectRunningTenantProcMetrics() []TenantProcessMetrics {
-	tenatProcessName := pmc.config.TenantProcessName
+	tenatProcessName := pmc.configStore.Get().TenantProcessName
 	processList, _ := process.Processes()
 	var tenantMetrics []TenantProcessMetrics
 
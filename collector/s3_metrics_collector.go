@@ -3,73 +3,176 @@ package collector
 import (
 	"ChintuIdrive/storage-node-watchdog/clients"
 	"ChintuIdrive/storage-node-watchdog/conf"
+	"ChintuIdrive/storage-node-watchdog/cryption"
 	"ChintuIdrive/storage-node-watchdog/dto"
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"time"
 )
 
 type S3Metrics struct {
 	DNS                   string                   `json:"dns"`
+	Endpoint              string                   `json:"endpoint"`
 	BucketsCount          int                      `json:"buckets_count"`
 	BucketListingDuration time.Duration            `json:"bucket_listing_duration"`
 	ObjectMetricsMap      map[string]ObjectMetrics `json:"object_metrics_map"`
 }
 
+// TenantS3Metrics holds the probe results of the public DNS and the local
+// minio endpoint separately, so a slow minio can be told apart from a slow
+// load balancer.
+type TenantS3Metrics struct {
+	DNS         string     `json:"dns"`
+	Public      *S3Metrics `json:"public,omitempty"`
+	Local       *S3Metrics `json:"local,omitempty"`
+	PublicError string     `json:"public_error,omitempty"`
+	LocalError  string     `json:"local_error,omitempty"`
+}
+
 type ObjectMetrics struct {
 	ObjectsCount           int           `json:"objects_count"`
 	ObjecttListingDuration time.Duration `json:"object_listing_duration"`
 }
 
 type S3MetricCollector struct {
-	controllerCliet *clients.ControllerClient
-	config          *conf.Config
+	controllerCliet   *clients.ControllerClient
+	credentialManager *clients.CredentialManager
+	configStore       *conf.ConfigStore
 }
 
-func NewS3MetricCollector(config *conf.Config, cc *clients.ControllerClient) *S3MetricCollector {
+func NewS3MetricCollector(configStore *conf.ConfigStore, cc *clients.ControllerClient, cm *clients.CredentialManager) *S3MetricCollector {
 	return &S3MetricCollector{
-		config:          config,
-		controllerCliet: cc,
+		configStore:       configStore,
+		controllerCliet:   cc,
+		credentialManager: cm,
 	}
 }
 
-func (s3mc *S3MetricCollector) CollectS3Metrics(tenat dto.Tenant) (*S3Metrics, error) {
-	s3config, err := s3mc.config.GetS3Config(tenat)
+func (s3mc *S3MetricCollector) CollectS3Metrics(ctx context.Context, tenat dto.Tenant) (*TenantS3Metrics, error) {
+	s3config, err := s3mc.configStore.Get().GetS3Config(tenat)
 	if err != nil {
-		log.Printf("Error getting S3 config for tenant %s: %v", tenat.DNS, err)
+		slog.Error("Failed to get S3 config", "tenant", tenat.DNS, "error", err)
 		return nil, err
 	}
 
-	acckey, err := s3mc.controllerCliet.GetSavedAccessKey(tenat)
+	acckey, err := s3mc.credentialManager.GetAccessKey(ctx, tenat)
 	if err != nil {
-		log.Printf("Error getting saved access key for tenant %s: %v", tenat.DNS, err)
-		acckey, err = s3mc.controllerCliet.GetAccessKeys(tenat)
+		return nil, err
+	}
+
+	tenantMetrics, keyRejected, err := s3mc.probeS3Endpoints(ctx, tenat, s3config, acckey)
+	if !keyRejected {
+		return tenantMetrics, err
+	}
+	newAcckey, rotateErr := s3mc.credentialManager.RotateAccessKey(ctx, tenat, acckey)
+	if rotateErr != nil {
+		slog.Error("Failed to rotate access key", "tenant", tenat.DNS, "error", rotateErr)
+		return tenantMetrics, err
+	}
+	tenantMetrics, _, err = s3mc.probeS3Endpoints(ctx, tenat, s3config, newAcckey)
+	return tenantMetrics, err
+}
+
+// probeS3Endpoints runs the probes on the selected endpoints. keyRejected is
+// set when any endpoint refused the access key.
+func (s3mc *S3MetricCollector) probeS3Endpoints(ctx context.Context, tenat dto.Tenant, s3config *conf.S3Config, acckey *cryption.SecretData) (*TenantS3Metrics, bool, error) {
+	ds := acckey.SecretKey.DString
+	tenantMetrics := &TenantS3Metrics{DNS: tenat.DNS}
+	keyRejected := false
+	var lastErr error
+	var err error
+	if s3config.ProbePublic() {
+		tenantMetrics.Public, err = s3mc.collectPublicS3Metrics(ctx, tenat, s3config, acckey.AccessKey, ds)
+		if err != nil {
+			slog.Warn("Failed to probe public S3 endpoint", "tenant", tenat.DNS, "error", err)
+			tenantMetrics.PublicError = err.Error()
+			keyRejected = keyRejected || clients.IsInvalidAccessKeyError(err)
+			lastErr = err
+		}
+	}
+
+	if s3config.ProbeLocal() {
+		tenantMetrics.Local, err = s3mc.collectLocalS3Metrics(ctx, tenat, s3config, acckey.AccessKey, ds)
 		if err != nil {
-			return nil, err
+			slog.Warn("Failed to probe local S3 endpoint", "tenant", tenat.DNS, "error", err)
+			tenantMetrics.LocalError = err.Error()
+			keyRejected = keyRejected || clients.IsInvalidAccessKeyError(err)
+			lastErr = err
+		}
+	}
+
+	if tenantMetrics.Public == nil && tenantMetrics.Local == nil {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no S3 endpoint selected for tenant %s", tenat.DNS)
 		}
+		return nil, keyRejected, lastErr
 	}
 
-	ds, err := acckey.SecretKey.GetDString()
+	return tenantMetrics, keyRejected, nil
+}
+
+func (s3mc *S3MetricCollector) collectPublicS3Metrics(ctx context.Context, tenat dto.Tenant, s3config *conf.S3Config, accessKey, secretKey string) (*S3Metrics, error) {
+	client, err := clients.NewS3Client(tenat.DNS, accessKey, secretKey)
+	if err != nil {
+		return nil, err
+	}
+	return probeTenantEndpoint(ctx, clients.TenantS3Dependency(tenat.DNS, "public"), func() (*S3Metrics, error) {
+		return collectEndpointS3Metrics(ctx, client, s3config, tenat.DNS, "https://"+tenat.DNS)
+	})
+}
+
+func (s3mc *S3MetricCollector) collectLocalS3Metrics(ctx context.Context, tenat dto.Tenant, s3config *conf.S3Config, accessKey, secretKey string) (*S3Metrics, error) {
+	processInfo, err := s3mc.controllerCliet.GetTenantProcessInfo(ctx, tenat)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := processInfo.LocalS3Endpoint()
+	useHttp := processInfo.TenatWithProcessInfo.UseHttp
+	scheme := "https://"
+	if useHttp {
+		scheme = "http://"
+	}
+	client, err := clients.NewLocalS3Client(endpoint, accessKey, secretKey, useHttp)
 	if err != nil {
 		return nil, err
 	}
-	acckey.SecretKey.DString = ds
-	log.Print(ds)
-	client := clients.NewS3Client(tenat.DNS, acckey.AccessKey, ds)
+	return probeTenantEndpoint(ctx, clients.TenantS3Dependency(tenat.DNS, "local"), func() (*S3Metrics, error) {
+		return collectEndpointS3Metrics(ctx, client, s3config, tenat.DNS, scheme+endpoint)
+	})
+}
+
+// probeTenantEndpoint runs the probe behind the endpoint's circuit breaker. A
+// rejected access key still means the endpoint answered.
+func probeTenantEndpoint(ctx context.Context, dependency string, probe func() (*S3Metrics, error)) (*S3Metrics, error) {
+	var s3metrics *S3Metrics
+	err := clients.GetDependencyRegistry().Call(ctx, dependency, func() error {
+		var err error
+		s3metrics, err = probe()
+		return err
+	}, func(err error) bool {
+		return !clients.IsInvalidAccessKeyError(err)
+	})
+	return s3metrics, err
+}
+
+func collectEndpointS3Metrics(ctx context.Context, client *clients.S3Client, s3config *conf.S3Config, dns, endpoint string) (*S3Metrics, error) {
 	startTime := time.Now()
-	buckets, err := client.ListBuckets()
+	buckets, err := client.ListBuckets(ctx)
 	duration := time.Since(startTime)
 	if err != nil {
 		return nil, err
 	}
 	s3metrics := &S3Metrics{
-		DNS:                   tenat.DNS,
+		DNS:                   dns,
+		Endpoint:              endpoint,
 		BucketsCount:          len(buckets),
 		BucketListingDuration: duration,
 		ObjectMetricsMap:      make(map[string]ObjectMetrics),
 	}
 
 	if s3config.BucketSelector == 0 {
-		log.Printf("No specific bucket selector configured for tenant %s, processing all buckets", tenat.DNS)
+		slog.Debug("No bucket selector configured, not listing objects", "tenant", dns)
 		return s3metrics, nil
 	}
 
@@ -79,11 +182,14 @@ func (s3mc *S3MetricCollector) CollectS3Metrics(tenat dto.Tenant) (*S3Metrics, e
 	}
 
 	for _, bucket := range bucketsToProcess {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		startTime = time.Now()
-		objCount, err := client.ListObjectsForBucket(*bucket.Name, s3config.PageSelector)
+		objCount, err := client.ListObjectsForBucket(ctx, *bucket.Name, s3config.PageSelector)
 		duration = time.Since(startTime)
 		if err != nil {
-			log.Printf("Error listing objects for bucket %s: %v", *bucket.Name, err)
+			slog.Warn("Failed to list objects", "tenant", dns, "bucket", *bucket.Name, "error", err)
 			continue
 		}
 		objMetric := ObjectMetrics{
@@ -91,7 +197,7 @@ func (s3mc *S3MetricCollector) CollectS3Metrics(tenat dto.Tenant) (*S3Metrics, e
 			ObjecttListingDuration: duration,
 		}
 		s3metrics.ObjectMetricsMap[*bucket.Name] = objMetric
-		log.Print(*bucket.Name)
+		slog.Debug("Listed objects", "tenant", dns, "bucket", *bucket.Name, "objects", objCount)
 	}
 
 	return s3metrics, nil
@@ -4,7 +4,7 @@ import (
 	"ChintuIdrive/storage-node-watchdog/conf"
 	"ChintuIdrive/storage-node-watchdog/dto"
 	"fmt"
-	"log"
+	"log/slog"
 	"path/filepath"
 	"reflect"
 	"time"
@@ -60,13 +60,13 @@ type DiskUsageStat struct {
 //var monitoreddisks = []string{"/", "/data1", "/data2", "/data3", "/data4"}
 
 type SystemStatsCollector struct {
-	config *conf.Config
+	configStore *conf.ConfigStore
 	//systemStats *SystemStats
 }
 
-func NewSystemStatsCollector(config *conf.Config) *SystemStatsCollector {
+func NewSystemStatsCollector(configStore *conf.ConfigStore) *SystemStatsCollector {
 	return &SystemStatsCollector{
-		config: config,
+		configStore: configStore,
 	}
 }
 
@@ -76,7 +76,7 @@ func (smc *SystemStatsCollector) CollectSystemMetrics() *SystemStats {
 	//log.Printf("Current time: %s", currentTime.Format(time.RFC1123))
 	uptime, err := host.Uptime()
 	if err != nil {
-		log.Printf("Error getting system uptime: %v", err)
+		slog.Error("Failed to get system uptime", "error", err)
 	}
 	uptimeDuration := time.Duration(uptime) * time.Second
 	uptimeStr := fmt.Sprintf("%d days, %d hours, and %d minutes", int(uptimeDuration.Hours())/24, int(uptimeDuration.Hours())%24, int(uptimeDuration.Minutes())%60)
@@ -121,11 +121,11 @@ func (smc *SystemStatsCollector) CollectSystemMetrics() *SystemStats {
 	}
 	// Disk I/O
 	//diskUsageMap := make(map[string]*DiskUsageStat)
-	monitoreddisks := smc.config.GetDisksToMonitor()
+	monitoreddisks := smc.configStore.Get().GetDisksToMonitor()
 	for _, diskName := range monitoreddisks {
 		diskUsageStat, err := disk.Usage(diskName)
 		if err != nil {
-			log.Printf("Error getting disk usage for %s: %v", diskName, err)
+			slog.Error("Failed to get disk usage", "disk", diskName, "error", err)
 			continue
 		}
 		devicePath, _ := getDeviceForMount(diskName)
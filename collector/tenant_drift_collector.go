@@ -0,0 +1,154 @@
+package collector
+
+import (
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TenantDrift struct {
+	DNS       string       `json:"dns"`
+	CheckedAt time.Time    `json:"checked_at"`
+	Fields    []FieldDrift `json:"fields"`
+}
+
+type FieldDrift struct {
+	Field   string    `json:"field"`
+	Desired string    `json:"desired"` // value from the API server
+	Running string    `json:"running"` // value reported by the controller
+	Since   time.Time `json:"since"`
+}
+
+type TenantDriftCollector struct {
+	controllerClient *clients.ControllerClient
+	lock             sync.RWMutex
+	driftMap         map[string]*TenantDrift
+}
+
+func NewTenantDriftCollector(cc *clients.ControllerClient) *TenantDriftCollector {
+	return &TenantDriftCollector{
+		controllerClient: cc,
+		driftMap:         make(map[string]*TenantDrift),
+	}
+}
+
+// CollectTenantDrift diffs the desired tenant configuration from the API server
+// against the running one from the controller. A field keeps the time it was
+// first seen drifting for as long as it stays different.
+func (tdc *TenantDriftCollector) CollectTenantDrift(ctx context.Context, tenant dto.Tenant) (*TenantDrift, error) {
+	running, err := tdc.controllerClient.GetTenantWithProcessInfo(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	tenantDrift := &TenantDrift{
+		DNS:       tenant.DNS,
+		CheckedAt: now,
+		Fields:    []FieldDrift{},
+	}
+
+	tdc.lock.Lock()
+	defer tdc.lock.Unlock()
+
+	previous := make(map[string]time.Time)
+	if last, found := tdc.driftMap[tenant.DNS]; found {
+		for _, field := range last.Fields {
+			previous[field.Field] = field.Since
+		}
+	}
+	for _, field := range diffTenantConfig(tenant, *running) {
+		field.Since = now
+		if since, found := previous[field.Field]; found {
+			field.Since = since
+		}
+		tenantDrift.Fields = append(tenantDrift.Fields, field)
+	}
+	tdc.driftMap[tenant.DNS] = tenantDrift
+
+	return tenantDrift, nil
+}
+
+// GetTenantDriftReport returns the result of the last check for every tenant.
+func (tdc *TenantDriftCollector) GetTenantDriftReport() map[string]*TenantDrift {
+	tdc.lock.RLock()
+	defer tdc.lock.RUnlock()
+
+	report := make(map[string]*TenantDrift, len(tdc.driftMap))
+	for dns, tenantDrift := range tdc.driftMap {
+		report[dns] = tenantDrift
+	}
+	return report
+}
+
+// RemoveTenant forgets the drift state of a tenant no longer on this node.
+func (tdc *TenantDriftCollector) RemoveTenant(dns string) {
+	tdc.lock.Lock()
+	defer tdc.lock.Unlock()
+	delete(tdc.driftMap, dns)
+}
+
+func diffTenantConfig(desired dto.Tenant, running dto.TenatWithProcessInfo) []FieldDrift {
+	pairs := []struct {
+		field   string
+		desired string
+		running string
+	}{
+		{"MaxAPIRequests", fmt.Sprint(desired.MaxAPIRequests), fmt.Sprint(running.MaxApiRequests)},
+		{"APIRequestsDeadline", fmt.Sprint(desired.APIRequestsDeadline), fmt.Sprint(running.ApiRequestsDeadline)},
+		{"Whitelist", normalizeList(desired.Whitelist), normalizeList(running.Whitelist)},
+		{"Blacklist", normalizeList(desired.Blacklist), normalizeList(running.Blacklist)},
+		{"Compression", fmt.Sprint(desired.Compression), fmt.Sprint(running.Compression)},
+		{"UseDEC", fmt.Sprint(desired.UseDEC), fmt.Sprint(running.UseDEC)},
+		{"AllowedOrigin", desired.AllowedOrigin, running.AllowedOrigin},
+		{"PublicBucketsEnabled", fmt.Sprint(desired.PublicBucketsEnabled), fmt.Sprint(running.PublicBucketsEnabled)},
+		{"CNameList", normalizeList(desired.CnameList), normalizeList(running.CNameList)},
+		{"UploadLimit", normalizeNumber(desired.UploadLimit), fmt.Sprint(running.UploadLimit)},
+		{"DownloadLimit", normalizeNumber(desired.DownloadLimit), fmt.Sprint(running.DownloadLimit)},
+	}
+
+	var drift []FieldDrift
+	for _, pair := range pairs {
+		if pair.desired != pair.running {
+			drift = append(drift, FieldDrift{Field: pair.field, Desired: pair.desired, Running: pair.running})
+		}
+	}
+	return drift
+}
+
+// normalizeList renders a list as a sorted comma separated string, so that
+// nil, empty and reordered lists compare equal.
+func normalizeList(list interface{}) string {
+	var values []string
+	switch l := list.(type) {
+	case []string:
+		values = append(values, l...)
+	case []interface{}:
+		for _, v := range l {
+			values = append(values, fmt.Sprint(v))
+		}
+	case nil:
+	default:
+		values = append(values, fmt.Sprint(l))
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+// normalizeNumber renders a JSON number decoded into an interface{}, treating
+// a missing value as zero like the controller does.
+func normalizeNumber(number interface{}) string {
+	switch n := number.(type) {
+	case nil:
+		return "0"
+	case float64:
+		return fmt.Sprint(int64(n))
+	default:
+		return fmt.Sprint(n)
+	}
+}
@@ -0,0 +1,266 @@
+package collector
+
+import (
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+type TenantEventType string
+
+const (
+	TenantAdded   TenantEventType = "added"
+	TenantRemoved TenantEventType = "removed"
+)
+
+// TenantEvent is published when a tenant appears in or disappears from the inventory.
+type TenantEvent struct {
+	Type   TenantEventType
+	Tenant dto.Tenant
+}
+
+// InventoryTenant is a tenant assigned to this node by the API server, with
+// the process the controller runs for it if any.
+type InventoryTenant struct {
+	Tenant      dto.Tenant
+	ProcessInfo *dto.TenatWithProcessInfo // nil when the controller runs no process for it
+}
+
+// TenantInventorySummary is the inventory entry shown by the API, without
+// the tenant password.
+type TenantInventorySummary struct {
+	DNS        string `json:"dns"`
+	UserID     string `json:"user_id"`
+	E2UserID   string `json:"e2_user_id"`
+	Assigned   bool   `json:"assigned"` // in the tenant list of the API server
+	Running    bool   `json:"running"`  // the controller runs a process for it
+	ProcessID  int    `json:"process_id,omitempty"`
+	AdminPort  int    `json:"admin_port,omitempty"`
+	S3Port     int    `json:"s3_port,omitempty"`
+	Restarting bool   `json:"restarting"`
+}
+
+type TenantInventoryReport struct {
+	RefreshedAt time.Time                 `json:"refreshed_at"`
+	TenantList  clients.TenantListStatus  `json:"tenant_list"`
+	Tenants     []*TenantInventorySummary `json:"tenants"`
+}
+
+// TenantInventory is the list of tenants every monitor and handler works on.
+// It merges the tenant list from the API server with the processes the
+// controller runs on this node and is refreshed on an interval, so that one
+// fetch serves all of them.
+type TenantInventory struct {
+	apiServerClient  *clients.APIserverClient
+	controllerClient *clients.ControllerClient
+	lock             sync.RWMutex
+	nodeInfo         *dto.TenantList
+	tenantMap        map[string]*InventoryTenant // by DNS
+	userIDMap        map[string]string           // user id to DNS
+	unassigned       map[string]*dto.TenatWithProcessInfo
+	refreshedAt      time.Time
+	subscribersLock  sync.RWMutex
+	subscribers      []func(TenantEvent)
+}
+
+func NewTenantInventory(asc *clients.APIserverClient, cc *clients.ControllerClient) *TenantInventory {
+	return &TenantInventory{
+		apiServerClient:  asc,
+		controllerClient: cc,
+		nodeInfo:         &dto.TenantList{},
+		tenantMap:        make(map[string]*InventoryTenant),
+		userIDMap:        make(map[string]string),
+		unassigned:       make(map[string]*dto.TenatWithProcessInfo),
+	}
+}
+
+// Subscribe registers a handler called with every add and remove event after
+// a refresh. Handlers must not block.
+func (tinv *TenantInventory) Subscribe(handler func(TenantEvent)) {
+	tinv.subscribersLock.Lock()
+	defer tinv.subscribersLock.Unlock()
+	tinv.subscribers = append(tinv.subscribers, handler)
+}
+
+// Refresh fetches the tenant list and the running processes and publishes
+// the tenants that were added or removed. The previous inventory is kept if
+// no tenant list can be had.
+func (tinv *TenantInventory) Refresh(ctx context.Context) error {
+	nodeInfo, err := tinv.apiServerClient.GetNodeInfoFromApiServer(ctx)
+	if err != nil {
+		return err
+	}
+	running, err := tinv.controllerClient.GetTenantListFromController()
+	if err != nil {
+		slog.Warn("Failed to read running tenants from controller", "error", err)
+	}
+
+	tenantMap := make(map[string]*InventoryTenant, len(nodeInfo.TenantList))
+	userIDMap := make(map[string]string, len(nodeInfo.TenantList))
+	for _, tenant := range nodeInfo.TenantList {
+		tenantMap[tenant.DNS] = &InventoryTenant{Tenant: tenant}
+		if tenant.UserID != "" {
+			userIDMap[tenant.UserID] = tenant.DNS
+		}
+	}
+	unassigned := make(map[string]*dto.TenatWithProcessInfo)
+	for i := range running {
+		processInfo := &running[i]
+		if inventoryTenant, found := tenantMap[processInfo.DNS]; found {
+			inventoryTenant.ProcessInfo = processInfo
+			continue
+		}
+		unassigned[processInfo.DNS] = processInfo
+	}
+
+	tinv.lock.Lock()
+	var events []TenantEvent
+	for dns, inventoryTenant := range tenantMap {
+		if _, found := tinv.tenantMap[dns]; !found {
+			events = append(events, TenantEvent{Type: TenantAdded, Tenant: inventoryTenant.Tenant})
+		}
+	}
+	for dns, inventoryTenant := range tinv.tenantMap {
+		if _, found := tenantMap[dns]; !found {
+			events = append(events, TenantEvent{Type: TenantRemoved, Tenant: inventoryTenant.Tenant})
+		}
+	}
+	for dns := range unassigned {
+		if _, found := tinv.unassigned[dns]; !found {
+			slog.Warn("Tenant runs on this node but is not in the tenant list of the API server", "tenant", dns)
+		}
+	}
+	tinv.nodeInfo = nodeInfo
+	tinv.tenantMap = tenantMap
+	tinv.userIDMap = userIDMap
+	tinv.unassigned = unassigned
+	tinv.refreshedAt = time.Now()
+	tinv.lock.Unlock()
+
+	tinv.publish(events)
+	return nil
+}
+
+func (tinv *TenantInventory) publish(events []TenantEvent) {
+	tinv.subscribersLock.RLock()
+	defer tinv.subscribersLock.RUnlock()
+	for _, event := range events {
+		slog.Info("Tenant inventory changed", "tenant", event.Tenant.DNS, "event", event.Type)
+		for _, handler := range tinv.subscribers {
+			handler(event)
+		}
+	}
+}
+
+// GetTenants returns the tenants assigned to this node, sorted by DNS.
+func (tinv *TenantInventory) GetTenants() []dto.Tenant {
+	tinv.lock.RLock()
+	defer tinv.lock.RUnlock()
+
+	tenants := make([]dto.Tenant, 0, len(tinv.tenantMap))
+	for _, inventoryTenant := range tinv.tenantMap {
+		tenants = append(tenants, inventoryTenant.Tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].DNS < tenants[j].DNS
+	})
+	return tenants
+}
+
+// GetTenant returns the tenant with the given DNS.
+func (tinv *TenantInventory) GetTenant(dns string) (dto.Tenant, bool) {
+	tinv.lock.RLock()
+	defer tinv.lock.RUnlock()
+
+	inventoryTenant, found := tinv.tenantMap[dns]
+	if !found {
+		return dto.Tenant{}, false
+	}
+	return inventoryTenant.Tenant, true
+}
+
+// GetTenantByUserID returns the tenant with the given minio user id.
+func (tinv *TenantInventory) GetTenantByUserID(userID string) (dto.Tenant, bool) {
+	tinv.lock.RLock()
+	dns, found := tinv.userIDMap[userID]
+	tinv.lock.RUnlock()
+	if !found {
+		return dto.Tenant{}, false
+	}
+	return tinv.GetTenant(dns)
+}
+
+// GetRunningProcessInfo returns the process the controller runs for the tenant
+// as of the last refresh.
+func (tinv *TenantInventory) GetRunningProcessInfo(dns string) (*dto.TenatWithProcessInfo, bool) {
+	tinv.lock.RLock()
+	defer tinv.lock.RUnlock()
+
+	inventoryTenant, found := tinv.tenantMap[dns]
+	if !found || inventoryTenant.ProcessInfo == nil {
+		return nil, false
+	}
+	processInfo := *inventoryTenant.ProcessInfo
+	return &processInfo, true
+}
+
+// GetNodeInfo returns the node level settings of the last tenant list.
+func (tinv *TenantInventory) GetNodeInfo() *dto.TenantList {
+	tinv.lock.RLock()
+	defer tinv.lock.RUnlock()
+
+	nodeInfo := *tinv.nodeInfo
+	nodeInfo.TenantList = append([]dto.Tenant{}, tinv.nodeInfo.TenantList...)
+	return &nodeInfo
+}
+
+// GetTenantInventoryReport lists the assigned tenants and the processes the
+// controller runs for tenants that are not assigned to this node.
+func (tinv *TenantInventory) GetTenantInventoryReport() *TenantInventoryReport {
+	tinv.lock.RLock()
+	defer tinv.lock.RUnlock()
+
+	report := &TenantInventoryReport{
+		RefreshedAt: tinv.refreshedAt,
+		TenantList:  tinv.apiServerClient.GetTenantListStatus(),
+		Tenants:     make([]*TenantInventorySummary, 0, len(tinv.tenantMap)+len(tinv.unassigned)),
+	}
+	for _, inventoryTenant := range tinv.tenantMap {
+		summary := &TenantInventorySummary{
+			DNS:      inventoryTenant.Tenant.DNS,
+			UserID:   inventoryTenant.Tenant.UserID,
+			E2UserID: inventoryTenant.Tenant.E2UserID,
+			Assigned: true,
+		}
+		addProcessSummary(summary, inventoryTenant.ProcessInfo)
+		report.Tenants = append(report.Tenants, summary)
+	}
+	for dns, processInfo := range tinv.unassigned {
+		summary := &TenantInventorySummary{
+			DNS:      dns,
+			UserID:   processInfo.UserID,
+			E2UserID: processInfo.E2UserID,
+		}
+		addProcessSummary(summary, processInfo)
+		report.Tenants = append(report.Tenants, summary)
+	}
+	sort.Slice(report.Tenants, func(i, j int) bool {
+		return report.Tenants[i].DNS < report.Tenants[j].DNS
+	})
+	return report
+}
+
+func addProcessSummary(summary *TenantInventorySummary, processInfo *dto.TenatWithProcessInfo) {
+	if processInfo == nil {
+		return
+	}
+	summary.Running = true
+	summary.ProcessID = processInfo.ProcessID
+	summary.AdminPort = processInfo.AdminPort
+	summary.S3Port = processInfo.S3Port
+	summary.Restarting = processInfo.RestartInProcess
+}
@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"sync"
+	"time"
+)
+
+type TenantRequestStats struct {
+	DNS                           string        `json:"dns"`
+	CheckedAt                     time.Time     `json:"checked_at"`
+	Interval                      time.Duration `json:"interval"` // time since the previous check
+	TotalRequestsProcessed        int64         `json:"total_requests_processed"`
+	RequestsDelta                 int64         `json:"requests_delta"`
+	RequestRate                   float64       `json:"request_rate"` // requests per second over the interval
+	PreviousRequestRate           float64       `json:"previous_request_rate"`
+	RequestsProcessInLastInterval bool          `json:"requests_process_in_last_interval"`
+	FailedS3HealthChecks          int           `json:"failed_s3_health_checks"`
+	FailedS3HealthChecksDelta     int           `json:"failed_s3_health_checks_delta"`
+	ErrorStat                     int           `json:"error_stat"`
+	ErrorStatDelta                int           `json:"error_stat_delta"`
+}
+
+type TenantRequestStatsCollector struct {
+	tenantInventory *TenantInventory
+	lock            sync.RWMutex
+	requestStatsMap map[string]*TenantRequestStats
+}
+
+// NewTenantRequestStatsCollector keeps the counters of the tenants in tinv,
+// those of a removed tenant are dropped with it.
+func NewTenantRequestStatsCollector(tinv *TenantInventory) *TenantRequestStatsCollector {
+	trsc := &TenantRequestStatsCollector{
+		tenantInventory: tinv,
+		requestStatsMap: make(map[string]*TenantRequestStats),
+	}
+	tinv.Subscribe(trsc.handleTenantEvent)
+	return trsc
+}
+
+func (trsc *TenantRequestStatsCollector) handleTenantEvent(event TenantEvent) {
+	if event.Type == TenantRemoved {
+		trsc.RemoveTenant(event.Tenant.DNS)
+	}
+}
+
+// CollectTenantRequestStats turns the counters the controller reports for the
+// tenant into deltas and rates since the previous call. The first call for a
+// tenant only records a baseline.
+func (trsc *TenantRequestStatsCollector) CollectTenantRequestStats(processInfo dto.TenatWithProcessInfo) *TenantRequestStats {
+	now := time.Now()
+	requestStats := &TenantRequestStats{
+		DNS:                           processInfo.DNS,
+		CheckedAt:                     now,
+		TotalRequestsProcessed:        processInfo.TotalRequestsProcessed,
+		RequestsProcessInLastInterval: processInfo.RequestsProcessInLastInterval,
+		FailedS3HealthChecks:          processInfo.FailedS3HealthChecks,
+		ErrorStat:                     processInfo.ErrorStat,
+	}
+
+	trsc.lock.Lock()
+	defer trsc.lock.Unlock()
+
+	if last, found := trsc.requestStatsMap[processInfo.DNS]; found {
+		requestStats.Interval = now.Sub(last.CheckedAt)
+		requestStats.PreviousRequestRate = last.RequestRate
+		requestStats.RequestsDelta = counterDelta(requestStats.TotalRequestsProcessed, last.TotalRequestsProcessed)
+		requestStats.FailedS3HealthChecksDelta = int(counterDelta(int64(requestStats.FailedS3HealthChecks), int64(last.FailedS3HealthChecks)))
+		requestStats.ErrorStatDelta = int(counterDelta(int64(requestStats.ErrorStat), int64(last.ErrorStat)))
+		if requestStats.Interval > 0 {
+			requestStats.RequestRate = float64(requestStats.RequestsDelta) / requestStats.Interval.Seconds()
+		}
+	}
+	// a cycle that started before the tenant was removed must not bring
+	// its counters back
+	if _, found := trsc.tenantInventory.GetTenant(processInfo.DNS); found {
+		trsc.requestStatsMap[processInfo.DNS] = requestStats
+	}
+
+	return requestStats
+}
+
+// counterDelta returns the increase of a counter, a counter that went down was
+// reset by a process restart and counts from zero.
+func counterDelta(current, previous int64) int64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// RemoveTenant forgets the counters of a tenant no longer on this node.
+func (trsc *TenantRequestStatsCollector) RemoveTenant(dns string) {
+	trsc.lock.Lock()
+	defer trsc.lock.Unlock()
+	delete(trsc.requestStatsMap, dns)
+}
+
+// GetTenantRequestStatsReport returns the latest request stats of every tenant.
+func (trsc *TenantRequestStatsCollector) GetTenantRequestStatsReport() map[string]*TenantRequestStats {
+	trsc.lock.RLock()
+	defer trsc.lock.RUnlock()
+
+	report := make(map[string]*TenantRequestStats, len(trsc.requestStatsMap))
+	for dns, requestStats := range trsc.requestStatsMap {
+		report[dns] = requestStats
+	}
+	return report
+}
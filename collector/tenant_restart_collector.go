@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
+	"sync"
+	"time"
+)
+
+// maxRestartHistory is the number of restarts kept per tenant
+const maxRestartHistory = 50
+
+type TenantRestartState struct {
+	DNS                   string         `json:"dns"`
+	ProcessID             int            `json:"process_id"`
+	ProcessStartTime      string         `json:"process_start_time"`
+	RestartRequested      bool           `json:"restart_requested"`
+	ForceRestart          bool           `json:"force_restart"`
+	RestartRequestedAt    time.Time      `json:"restart_requested_at"` // zero when no request is pending
+	PlannedRestart        bool           `json:"planned_restart"`
+	MarkedForForceRestart bool           `json:"marked_for_force_restart"`
+	RestartInProcessSince time.Time      `json:"restart_in_process_since"` // zero when not restarting
+	CheckedAt             time.Time      `json:"checked_at"`
+	History               []RestartEvent `json:"history"`
+}
+
+type RestartEvent struct {
+	DetectedAt          time.Time `json:"detected_at"`
+	OldProcessID        int       `json:"old_process_id"`
+	NewProcessID        int       `json:"new_process_id"`
+	NewProcessStartTime string    `json:"new_process_start_time"`
+	Requested           bool      `json:"requested"` // restart was asked for by the API server
+}
+
+type TenantRestartCollector struct {
+	controllerClient *clients.ControllerClient
+	lock             sync.RWMutex
+	restartMap       map[string]*TenantRestartState
+}
+
+func NewTenantRestartCollector(cc *clients.ControllerClient) *TenantRestartCollector {
+	return &TenantRestartCollector{
+		controllerClient: cc,
+		restartMap:       make(map[string]*TenantRestartState),
+	}
+}
+
+// CollectTenantRestartState compares the tenant process with the one seen on
+// the previous call to detect restarts, and tracks how long restart requests
+// and restarts in process have been pending.
+func (trc *TenantRestartCollector) CollectTenantRestartState(ctx context.Context, tenant dto.Tenant) (*TenantRestartState, error) {
+	processInfo, err := trc.controllerClient.GetTenantWithProcessInfo(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	trc.lock.Lock()
+	defer trc.lock.Unlock()
+
+	state, found := trc.restartMap[tenant.DNS]
+	if !found {
+		state = &TenantRestartState{
+			DNS:              tenant.DNS,
+			ProcessID:        processInfo.ProcessID,
+			ProcessStartTime: processInfo.ProcessStartTime,
+			History:          []RestartEvent{},
+		}
+		trc.restartMap[tenant.DNS] = state
+	}
+
+	requested := tenant.Restart || tenant.ForceRestart
+	// Only a new request arms the deadline, a flag left set after the
+	// restart is done must not be counted twice
+	if requested && !state.RestartRequested {
+		state.RestartRequestedAt = now
+	}
+	if !requested {
+		state.RestartRequestedAt = time.Time{}
+	}
+
+	if processInfo.ProcessID != state.ProcessID || processInfo.ProcessStartTime != state.ProcessStartTime {
+		state.History = append(state.History, RestartEvent{
+			DetectedAt:          now,
+			OldProcessID:        state.ProcessID,
+			NewProcessID:        processInfo.ProcessID,
+			NewProcessStartTime: processInfo.ProcessStartTime,
+			Requested:           !state.RestartRequestedAt.IsZero(),
+		})
+		if len(state.History) > maxRestartHistory {
+			state.History = state.History[len(state.History)-maxRestartHistory:]
+		}
+		state.ProcessID = processInfo.ProcessID
+		state.ProcessStartTime = processInfo.ProcessStartTime
+		state.RestartRequestedAt = time.Time{}
+	}
+
+	if processInfo.RestartInProcess {
+		if state.RestartInProcessSince.IsZero() {
+			state.RestartInProcessSince = now
+		}
+	} else {
+		state.RestartInProcessSince = time.Time{}
+	}
+
+	state.RestartRequested = requested
+	state.ForceRestart = tenant.ForceRestart
+	state.PlannedRestart = processInfo.PlannedRestart
+	state.MarkedForForceRestart = processInfo.MarkedForForceRestart
+	state.CheckedAt = now
+
+	stateCopy := *state
+	stateCopy.History = append([]RestartEvent{}, state.History...)
+	return &stateCopy, nil
+}
+
+// RestartsSince returns the number of restarts detected after the given time.
+func (state *TenantRestartState) RestartsSince(since time.Time) int {
+	count := 0
+	for _, event := range state.History {
+		if event.DetectedAt.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// GetTenantRestartReport returns the restart state and history of every tenant.
+func (trc *TenantRestartCollector) GetTenantRestartReport() map[string]*TenantRestartState {
+	trc.lock.RLock()
+	defer trc.lock.RUnlock()
+
+	report := make(map[string]*TenantRestartState, len(trc.restartMap))
+	for dns, state := range trc.restartMap {
+		stateCopy := *state
+		stateCopy.History = append([]RestartEvent{}, state.History...)
+		report[dns] = &stateCopy
+	}
+	return report
+}
+
+// RemoveTenant forgets the restart state of a tenant no longer on this node.
+func (trc *TenantRestartCollector) RemoveTenant(dns string) {
+	trc.lock.Lock()
+	defer trc.lock.Unlock()
+	delete(trc.restartMap, dns)
+}
@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type TenantUsage struct {
+	DNS                string                 `json:"dns"`
+	LastUpdate         time.Time              `json:"last_update"`
+	BucketsCount       uint64                 `json:"buckets_count"`
+	ObjectsCount       uint64                 `json:"objects_count"`
+	ObjectsTotalSize   uint64                 `json:"objects_total_size"`
+	VersionsCount      uint64                 `json:"versions_count"`
+	DeleteMarkersCount uint64                 `json:"delete_markers_count"`
+	BucketUsageMap     map[string]BucketUsage `json:"bucket_usage_map"`
+}
+
+type BucketUsage struct {
+	Size               uint64    `json:"size"`
+	ObjectsCount       uint64    `json:"objects_count"`
+	VersionsCount      uint64    `json:"versions_count"`
+	DeleteMarkersCount uint64    `json:"delete_markers_count"`
+	Created            time.Time `json:"created"`
+}
+
+type TenantUsageCollector struct {
+	controllerClient *clients.ControllerClient
+}
+
+func NewTenantUsageCollector(cc *clients.ControllerClient) *TenantUsageCollector {
+	return &TenantUsageCollector{
+		controllerClient: cc,
+	}
+}
+
+// CollectTenantUsage reads the usage minio's scanner already keeps for the
+// tenant, so no objects have to be listed.
+func (tuc *TenantUsageCollector) CollectTenantUsage(ctx context.Context, tenant dto.Tenant) (*TenantUsage, error) {
+	processInfo, err := tuc.controllerClient.GetTenantProcessInfo(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	adminClient, err := clients.NewTenantAdminClient(tenant, processInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	dataUsage, err := adminClient.DataUsageInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenantUsage := &TenantUsage{
+		DNS:              tenant.DNS,
+		LastUpdate:       dataUsage.LastUpdate,
+		BucketsCount:     dataUsage.BucketsCount,
+		ObjectsCount:     dataUsage.ObjectsTotalCount,
+		ObjectsTotalSize: dataUsage.ObjectsTotalSize,
+		BucketUsageMap:   make(map[string]BucketUsage),
+	}
+	for bucket, usage := range dataUsage.BucketsUsage {
+		tenantUsage.VersionsCount += usage.VersionsCount
+		tenantUsage.DeleteMarkersCount += usage.DeleteMarkersCount
+		tenantUsage.BucketUsageMap[bucket] = BucketUsage{
+			Size:               usage.Size,
+			ObjectsCount:       usage.ObjectsCount,
+			VersionsCount:      usage.VersionsCount,
+			DeleteMarkersCount: usage.DeleteMarkersCount,
+		}
+	}
+
+	// Account info knows buckets the scanner has not reached yet
+	accountInfo, err := adminClient.AccountInfo(ctx)
+	if err != nil {
+		slog.Warn("Failed to get account info", "tenant", tenant.DNS, "error", err)
+		return tenantUsage, nil
+	}
+	for _, bucket := range accountInfo.Buckets {
+		usage, found := tenantUsage.BucketUsageMap[bucket.Name]
+		if !found {
+			usage.Size = bucket.Size
+			usage.ObjectsCount = bucket.Objects
+		}
+		usage.Created = bucket.Created
+		tenantUsage.BucketUsageMap[bucket.Name] = usage
+	}
+	if uint64(len(tenantUsage.BucketUsageMap)) > tenantUsage.BucketsCount {
+		tenantUsage.BucketsCount = uint64(len(tenantUsage.BucketUsageMap))
+	}
+
+	return tenantUsage, nil
+}
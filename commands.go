@@ -0,0 +1,272 @@
+package main
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"ChintuIdrive/storage-node-watchdog/api"
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"ChintuIdrive/storage-node-watchdog/logging"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+func checkConfigCommand(args []string) error {
+	var opts options
+	newFlagSet("check-config", &opts).Parse(args)
+	return checkConfig(&opts)
+}
+
+// checkConfig returns the problems of the config, a file in an older schema
+// version included. Unlike the other commands it neither creates nor
+// migrates the file.
+func checkConfig(opts *options) error {
+	config, version, err := conf.ReadConfig(opts.configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := opts.overrides(config); err != nil {
+		return err
+	}
+	if err := config.LoadAPICredentials(); err != nil {
+		return err
+	}
+	var migrationErr error
+	if version < conf.CurrentSchemaVersion {
+		migrationErr = fmt.Errorf("%s needs migration from schema version %d to %d, the next run rewrites it", opts.configPath, version, conf.CurrentSchemaVersion)
+	}
+	if err := errors.Join(migrationErr, config.Validate()); err != nil {
+		return err
+	}
+	fmt.Printf("%s is valid\n", opts.configPath)
+	return nil
+}
+
+// OnceReport is everything the monitors collect, collected once.
+type OnceReport struct {
+	CollectedAt     time.Time                        `json:"collected_at"`
+	System          *collector.SystemStats           `json:"system"`
+	Processes       []collector.ProcessMetrics       `json:"processes"`
+	RunningTenants  []collector.TenantProcessMetrics `json:"running_tenants"`
+	TenantInventory *collector.TenantInventoryReport `json:"tenant_inventory"`
+	Tenants         []TenantOnceReport               `json:"tenants"`
+}
+
+// TenantOnceReport holds the metrics of one tenant, Errors has the collectors
+// that failed by name.
+type TenantOnceReport struct {
+	DNS          string                        `json:"dns"`
+	S3Metrics    *collector.TenantS3Metrics    `json:"s3_metrics,omitempty"`
+	Usage        *collector.TenantUsage        `json:"usage,omitempty"`
+	MinioHealth  *collector.MinioHealth        `json:"minio_health,omitempty"`
+	HealStatus   *collector.TenantHealStatus   `json:"heal_status,omitempty"`
+	Drift        *collector.TenantDrift        `json:"drift,omitempty"`
+	Restarts     *collector.TenantRestartState `json:"restarts,omitempty"`
+	RequestStats *collector.TenantRequestStats `json:"request_stats,omitempty"`
+	Errors       map[string]string             `json:"errors,omitempty"`
+}
+
+func onceCommand(args []string) error {
+	var opts options
+	newFlagSet("once", &opts).Parse(args)
+	wd, err := opts.newWatchdog()
+	if err != nil {
+		return err
+	}
+
+	report := OnceReport{
+		CollectedAt:     time.Now(),
+		System:          wd.ssc.CollectSystemMetrics(),
+		Processes:       wd.pmc.CollectProcessMetrics(),
+		RunningTenants:  wd.pmc.CollectRunningTenantProcMetrics(),
+		TenantInventory: wd.tinv.GetTenantInventoryReport(),
+	}
+	for _, tenant := range wd.tinv.GetTenants() {
+		tenantReport := TenantOnceReport{DNS: tenant.DNS, Errors: make(map[string]string)}
+		collect := func(name string, err error) {
+			if err != nil {
+				tenantReport.Errors[name] = err.Error()
+			}
+		}
+		tenantReport.S3Metrics, err = wd.s3mc.CollectS3Metrics(context.Background(), tenant)
+		collect("s3_metrics", err)
+		tenantReport.Usage, err = wd.tuc.CollectTenantUsage(context.Background(), tenant)
+		collect("usage", err)
+		tenantReport.MinioHealth, err = wd.mhc.CollectMinioHealth(context.Background(), tenant)
+		collect("minio_health", err)
+		tenantReport.HealStatus, err = wd.hsc.CollectHealStatus(context.Background(), tenant)
+		collect("heal_status", err)
+		tenantReport.Drift, err = wd.tdc.CollectTenantDrift(context.Background(), tenant)
+		collect("drift", err)
+		tenantReport.Restarts, err = wd.trc.CollectTenantRestartState(context.Background(), tenant)
+		collect("restarts", err)
+		if processInfo, found := wd.tinv.GetRunningProcessInfo(tenant.DNS); found {
+			tenantReport.RequestStats = wd.trsc.CollectTenantRequestStats(*processInfo)
+		}
+		report.Tenants = append(report.Tenants, tenantReport)
+	}
+	return printJSON(report)
+}
+
+func tenantsCommand(args []string) error {
+	var opts options
+	newFlagSet("tenants", &opts).Parse(args)
+	wd, err := opts.newWatchdog()
+	if err != nil {
+		return err
+	}
+	return printJSON(wd.tinv.GetTenantInventoryReport())
+}
+
+func probeCommand(args []string) error {
+	var opts options
+	fs := newFlagSet("probe", &opts)
+	dns := fs.String("tenant", "", "DNS of the tenant to probe")
+	fs.Parse(args)
+	if *dns == "" {
+		return errors.New("--tenant is required")
+	}
+	wd, err := opts.newWatchdog()
+	if err != nil {
+		return err
+	}
+	tenant, found := wd.tinv.GetTenant(*dns)
+	if !found {
+		return fmt.Errorf("tenant %s is not in the tenant inventory of this node", *dns)
+	}
+	s3Metrics, err := wd.s3mc.CollectS3Metrics(context.Background(), tenant)
+	if err != nil {
+		return err
+	}
+	return printJSON(s3Metrics)
+}
+
+// alertsCommand fetches the active alerts from the HTTP API of the watchdog
+// running with the same config.
+func alertsCommand(args []string) error {
+	var opts options
+	newFlagSet("alerts", &opts).Parse(args)
+	configStore, err := opts.readConfigStore()
+	if err != nil {
+		return err
+	}
+	config := configStore.Get()
+	if config.ApiServerConfig == nil {
+		return errors.New("api-server-config is missing, the address of the watchdog is unknown")
+	}
+	url, err := apiURL(config.ApiServerConfig.APIPort, config.HTTPAPI.TLS.Enabled(), "/api/v1/alerts")
+	if err != nil {
+		return err
+	}
+	client, err := newAPIClient(config.HTTPAPI.TLS)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	authorize(req, config.HTTPAPI.Auth)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the watchdog: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the alerts: %w", err)
+	}
+	var alerts []alert.Alert
+	envelope := api.Envelope{Data: &alerts}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s returned %s: %s", url, resp.Status, body)
+		}
+		return fmt.Errorf("failed to decode the alerts: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(envelope.Errors) > 0 {
+			return fmt.Errorf("%s returned %s: %s", url, resp.Status, envelope.Errors[0].Message)
+		}
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return printJSON(alerts)
+}
+
+// apiURL turns the listen address of the HTTP API into a URL, an address
+// listening on all interfaces is reached on localhost.
+func apiURL(listen string, https bool, path string) (string, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid api-port %q: %w", listen, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	scheme := "http://"
+	if https {
+		scheme = "https://"
+	}
+	return scheme + net.JoinHostPort(host, port) + path, nil
+}
+
+// newAPIClient returns a client for the HTTP API of the local watchdog. It
+// trusts the certificate of the API, which is often self-signed.
+func newAPIClient(tlsConfig conf.APITLSConfig) (*http.Client, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if !tlsConfig.Enabled() {
+		return client, nil
+	}
+	if tlsConfig.ClientCAFile != "" {
+		return nil, errors.New("http-api.tls.client-ca-file is set, the HTTP API only accepts clients with a certificate")
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	pem, err := os.ReadFile(tlsConfig.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	pool.AppendCertsFromPEM(pem)
+	client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	return client, nil
+}
+
+// authorize adds the first credential of http-api.auth to req, any scope
+// can read.
+func authorize(req *http.Request, auth conf.APIAuthConfig) {
+	if len(auth.Tokens) > 0 {
+		req.Header.Set("Authorization", "Bearer "+auth.Tokens[0].Token)
+	} else if len(auth.HMACKeys) > 0 {
+		api.SignRequest(req, nil, auth.HMACKeys[0])
+	}
+}
+
+// newWatchdog loads the valid config for a one-shot command, which logs to
+// stderr and keeps stdout for its result.
+func (opts *options) newWatchdog() (*watchdog, error) {
+	configStore, err := opts.readValidConfigStore()
+	if err != nil {
+		return nil, err
+	}
+	if err := logging.Setup(os.Stderr, configStore.Get().GetLoggingConfig()); err != nil {
+		return nil, err
+	}
+	return newWatchdog(context.Background(), configStore)
+}
+
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -3,21 +3,215 @@ package conf
 import (
 	"ChintuIdrive/storage-node-watchdog/cryption"
 	"ChintuIdrive/storage-node-watchdog/dto"
+	"bytes"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"text/template"
 	"time"
 )
 
+// CurrentSchemaVersion is the config.json format written by this version,
+// LoadConfig migrates older files to it.
+const CurrentSchemaVersion = 1
+
 type Config struct {
+	SchemaVersion        int                  `json:"schema-version"`
 	LogFilePath          string               `json:"log-file-path"`
+	Logging              LoggingConfig        `json:"logging"`
 	TenantProcessName    string               `json:"tenant-process-name"`
 	MonitoredProcesses   []string             `json:"monitored-processes"`
 	MonitoredDisks       []string             `json:"monitored-disks"`
 	ApiServerConfig      *ApiServerConfig     `json:"api-server-config"`
 	ControllerConfig     *ControllerConfig    `json:"controller-config"`
 	SystemLevelThreshold SystemLevelThreshold `json:"system-level-threshold"`
+	// FleetMinioVersion is the minio release every tenant is expected to run,
+	// leave it empty to skip the version check.
+	FleetMinioVersion string `json:"fleet-minio-version"`
+	// TenantDriftGracePeriod is how long the running tenant configuration may
+	// differ from the API server before an alert is raised.
+	TenantDriftGracePeriod Duration               `json:"tenant-drift-grace-period"`
+	TenantRestartThreshold TenantRestartThreshold `json:"tenant-restart-threshold"`
+	// TenantInventoryRefreshInterval is how often the tenant list is fetched
+	// for all monitors and handlers.
+	TenantInventoryRefreshInterval Duration `json:"tenant-inventory-refresh-interval"`
+	// KeyringFile holds the keys shared with the API server and the controller,
+	// the WATCHDOG_KEYRING environment variable takes precedence.
+	KeyringFile    string               `json:"keyring-file"`
+	HTTPClient     HTTPClientConfig     `json:"http-client"`
+	CircuitBreaker CircuitBreakerConfig `json:"circuit-breaker"`
+	// HTTPAPI secures the HTTP API served on api-server-config.api-port.
+	HTTPAPI HTTPAPIConfig `json:"http-api"`
+	// ShutdownTimeout is how long in-flight requests and monitor cycles may
+	// take to finish on SIGINT or SIGTERM.
+	ShutdownTimeout Duration `json:"shutdown-timeout"`
+	// Schedules overrides when each monitor runs, by monitor name, e.g.
+	// "system-stats" or "tenant-s3-stats". Fields left out keep the default.
+	Schedules map[string]ScheduleConfig `json:"schedules,omitempty"`
+}
+
+// ScheduleConfig says when a monitor runs. Every cycle starts Interval plus
+// a random part of Jitter after the previous one started, the first one
+// InitialDelay after start. A cycle running longer than Timeout is cancelled,
+// zero means no timeout.
+type ScheduleConfig struct {
+	Interval     Duration `json:"interval"`
+	InitialDelay Duration `json:"initial-delay"`
+	Jitter       Duration `json:"jitter"`
+	Timeout      Duration `json:"timeout"`
+}
+
+// GetSchedule returns the schedule of the named monitor, with the fields
+// missing from config.json taken from defaults.
+func (config *Config) GetSchedule(name string, defaults ScheduleConfig) ScheduleConfig {
+	schedule, found := config.Schedules[name]
+	if !found {
+		return defaults
+	}
+	if schedule.Interval.Duration == 0 {
+		schedule.Interval = defaults.Interval
+	}
+	if schedule.InitialDelay.Duration == 0 {
+		schedule.InitialDelay = defaults.InitialDelay
+	}
+	if schedule.Jitter.Duration == 0 {
+		schedule.Jitter = defaults.Jitter
+	}
+	if schedule.Timeout.Duration == 0 {
+		schedule.Timeout = defaults.Timeout
+	}
+	return schedule
+}
+
+const defaultShutdownTimeout = 30 * time.Second
+
+// GetShutdownTimeout returns the shutdown timeout, or the default if it is
+// not set.
+func (config *Config) GetShutdownTimeout() time.Duration {
+	if config.ShutdownTimeout.Duration == 0 {
+		return defaultShutdownTimeout
+	}
+	return config.ShutdownTimeout.Duration
+}
+
+// LoggingConfig controls the log written to log-file-path. The file is
+// rotated when it reaches MaxSize or is RotateEvery old, whichever comes
+// first, and rotated files beyond MaxBackups or older than MaxAge are removed.
+type LoggingConfig struct {
+	Level       string   `json:"level"`  // debug, info, warn or error
+	Format      string   `json:"format"` // text or json
+	MaxSize     ByteSize `json:"max-size"`
+	RotateEvery Duration `json:"rotate-every"` // zero disables time based rotation
+	MaxBackups  int      `json:"max-backups"`
+	MaxAge      Duration `json:"max-age"`
+}
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+func getDefaultLoggingConfig() LoggingConfig {
+	return LoggingConfig{
+		Level:      "info",
+		Format:     LogFormatText,
+		MaxSize:    100 << 20,
+		MaxBackups: 10,
+		MaxAge:     NewDuration(30 * 24 * time.Hour),
+	}
+}
+
+// GetLoggingConfig returns the logging settings with defaults filled in for
+// the fields missing from config.json.
+func (config *Config) GetLoggingConfig() LoggingConfig {
+	lc := config.Logging
+	defaults := getDefaultLoggingConfig()
+	if lc.Level == "" {
+		lc.Level = defaults.Level
+	}
+	if lc.Format == "" {
+		lc.Format = defaults.Format
+	}
+	if lc.MaxSize == 0 {
+		lc.MaxSize = defaults.MaxSize
+	}
+	if lc.MaxBackups == 0 {
+		lc.MaxBackups = defaults.MaxBackups
+	}
+	if lc.MaxAge.Duration == 0 {
+		lc.MaxAge = defaults.MaxAge
+	}
+	return lc
+}
+
+// CircuitBreakerConfig controls when calls to a failing dependency are
+// stopped and when they are tried again.
+type CircuitBreakerConfig struct {
+	FailureThreshold int      `json:"failure-threshold"` // consecutive failures that open the circuit
+	CoolDown         Duration `json:"cool-down"`         // time before a trial call is let through
+}
+
+// GetCircuitBreakerConfig returns the circuit breaker settings with defaults
+// filled in for the fields missing from config.json.
+func (config *Config) GetCircuitBreakerConfig() CircuitBreakerConfig {
+	cbc := config.CircuitBreaker
+	if cbc.FailureThreshold == 0 {
+		cbc.FailureThreshold = 5
+	}
+	if cbc.CoolDown.Duration == 0 {
+		cbc.CoolDown = NewDuration(1 * time.Minute)
+	}
+	return cbc
+}
+
+// HTTPClientConfig tunes the client used for API server and controller calls.
+type HTTPClientConfig struct {
+	Timeout         Duration `json:"timeout"`     // per attempt
+	MaxRetries      int      `json:"max-retries"` // negative disables retries
+	InitialBackoff  Duration `json:"initial-backoff"`
+	MaxBackoff      Duration `json:"max-backoff"`
+	MaxIdleConns    int      `json:"max-idle-conns"`
+	IdleConnTimeout Duration `json:"idle-conn-timeout"`
+}
+
+func getDefaultHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		Timeout:         NewDuration(30 * time.Second),
+		MaxRetries:      3,
+		InitialBackoff:  NewDuration(500 * time.Millisecond),
+		MaxBackoff:      NewDuration(10 * time.Second),
+		MaxIdleConns:    100,
+		IdleConnTimeout: NewDuration(90 * time.Second),
+	}
+}
+
+// GetHTTPClientConfig returns the HTTP client settings with defaults filled
+// in for the fields missing from config.json.
+func (config *Config) GetHTTPClientConfig() HTTPClientConfig {
+	hcc := config.HTTPClient
+	defaults := getDefaultHTTPClientConfig()
+	if hcc.Timeout.Duration == 0 {
+		hcc.Timeout = defaults.Timeout
+	}
+	if hcc.MaxRetries == 0 {
+		hcc.MaxRetries = defaults.MaxRetries
+	}
+	if hcc.InitialBackoff.Duration == 0 {
+		hcc.InitialBackoff = defaults.InitialBackoff
+	}
+	if hcc.MaxBackoff.Duration == 0 {
+		hcc.MaxBackoff = defaults.MaxBackoff
+	}
+	if hcc.MaxIdleConns == 0 {
+		hcc.MaxIdleConns = defaults.MaxIdleConns
+	}
+	if hcc.IdleConnTimeout.Duration == 0 {
+		hcc.IdleConnTimeout = defaults.IdleConnTimeout
+	}
+	return hcc
 }
 
 type ApiServerConfig struct {
@@ -26,6 +220,130 @@ type ApiServerConfig struct {
 	APIServerKey  string `json:"api-server-key"`
 	APIServerDNS  string `json:"api-server-dns"`
 	TenantListApi string `json:"tenant-list-api"`
+	// TenantListCacheFile keeps the last tenant list fetched from the API
+	// server, it is served while the API server is unreachable.
+	TenantListCacheFile string `json:"tenant-list-cache-file"`
+	// MergeControllerTenants adds the tenants the controller runs on this node
+	// to the cached list while the API server is unreachable. On unless set
+	// to false.
+	MergeControllerTenants *bool `json:"merge-controller-tenants,omitempty"`
+}
+
+const defaultTenantListCacheFile = "conf/tenant_list_cache.json"
+
+// HTTPAPIConfig holds the TLS and authentication settings of the HTTP API.
+type HTTPAPIConfig struct {
+	TLS  APITLSConfig  `json:"tls"`
+	Auth APIAuthConfig `json:"auth"`
+}
+
+// APITLSConfig serves the HTTP API over HTTPS when CertFile and KeyFile are
+// set. With ClientCAFile clients must present a certificate signed by one of
+// the CAs in it.
+type APITLSConfig struct {
+	CertFile     string `json:"cert-file"`
+	KeyFile      string `json:"key-file"`
+	ClientCAFile string `json:"client-ca-file"`
+}
+
+func (tlsConfig APITLSConfig) Enabled() bool {
+	return tlsConfig.CertFile != ""
+}
+
+// Scopes of the HTTP API credentials, admin includes read.
+const (
+	APIScopeRead  = "read"
+	APIScopeAdmin = "admin"
+)
+
+// APIAuthConfig lists the credentials accepted by the HTTP API. Without any
+// token or HMAC key the admin endpoints are disabled and the read endpoints
+// are only served to clients with a certificate, see APITLSConfig, or to
+// anyone with AllowAnonymousRead.
+type APIAuthConfig struct {
+	Tokens   []APIToken   `json:"tokens,omitempty"`
+	HMACKeys []APIHMACKey `json:"hmac-keys,omitempty"`
+	// AllowAnonymousRead serves the read endpoints without credentials while
+	// there is no token or HMAC key, as older versions did.
+	AllowAnonymousRead bool `json:"allow-anonymous-read,omitempty"`
+	// CredentialsFile adds the tokens and hmac-keys of a JSON file laid out
+	// like this section, so secrets can be kept out of config.json. It is
+	// read on start and on every reload.
+	CredentialsFile string `json:"credentials-file,omitempty"`
+	// MaxClockSkew is how far the timestamp of a signed request may be from
+	// the clock of the node.
+	MaxClockSkew Duration `json:"max-clock-skew"`
+}
+
+// APIToken is sent as "Authorization: Bearer <token>".
+type APIToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Scope string `json:"scope"`
+}
+
+// APIHMACKey is a key shared with a client that signs its requests.
+type APIHMACKey struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+	Scope  string `json:"scope"`
+}
+
+const defaultMaxClockSkew = 5 * time.Minute
+
+func (auth APIAuthConfig) Enabled() bool {
+	return len(auth.Tokens) > 0 || len(auth.HMACKeys) > 0
+}
+
+// LoadAPICredentials adds the credentials of http-api.auth.credentials-file
+// to the config.
+func (config *Config) LoadAPICredentials() error {
+	auth := &config.HTTPAPI.Auth
+	if auth.CredentialsFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(auth.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("http-api.auth.credentials-file: %w", err)
+	}
+	var credentials struct {
+		Tokens   []APIToken   `json:"tokens"`
+		HMACKeys []APIHMACKey `json:"hmac-keys"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&credentials); err != nil {
+		return fmt.Errorf("http-api.auth.credentials-file %s: %v", auth.CredentialsFile, err)
+	}
+	auth.Tokens = append(auth.Tokens, credentials.Tokens...)
+	auth.HMACKeys = append(auth.HMACKeys, credentials.HMACKeys...)
+	return nil
+}
+
+// GetAPIAuthConfig returns the HTTP API credentials with the default clock
+// skew filled in.
+func (config *Config) GetAPIAuthConfig() APIAuthConfig {
+	auth := config.HTTPAPI.Auth
+	if auth.MaxClockSkew.Duration == 0 {
+		auth.MaxClockSkew = NewDuration(defaultMaxClockSkew)
+	}
+	return auth
+}
+
+func (apiServerConfig *ApiServerConfig) GetTenantListCacheFile() string {
+	if apiServerConfig.TenantListCacheFile == "" {
+		return defaultTenantListCacheFile
+	}
+	return apiServerConfig.TenantListCacheFile
+}
+
+// GetMergeControllerTenants returns MergeControllerTenants, true if it is
+// missing from config.json.
+func (apiServerConfig *ApiServerConfig) GetMergeControllerTenants() bool {
+	if apiServerConfig.MergeControllerTenants == nil {
+		return true
+	}
+	return *apiServerConfig.MergeControllerTenants
 }
 
 type ControllerConfig struct {
@@ -33,6 +351,98 @@ type ControllerConfig struct {
 	ControllerDNS        string `json:"controller-dns"`
 	AddServiceAccountApi string `json:"add-service-account-api"`
 	GetTenantInfoApi     string `json:"get-tenant-info-api"`
+	// AccessKeyRefreshWindow is how long before expiry a watchdog access key is rotated
+	AccessKeyRefreshWindow Duration             `json:"access-key-refresh-window"`
+	ServiceAccount         ServiceAccountConfig `json:"service-account"`
+	// CredentialKeyFile holds the key the cached access keys are encrypted with,
+	// the WATCHDOG_CREDENTIAL_KEY environment variable takes precedence.
+	CredentialKeyFile string `json:"credential-key-file"`
+}
+
+const defaultCredentialKeyFile = "conf/credential.key"
+
+func (controllerConfig *ControllerConfig) GetCredentialKeyFile() string {
+	if controllerConfig.CredentialKeyFile == "" {
+		return defaultCredentialKeyFile
+	}
+	return controllerConfig.CredentialKeyFile
+}
+
+// ServiceAccountConfig describes the service account the watchdog creates in
+// every tenant for its S3 probes.
+type ServiceAccountConfig struct {
+	Name        string   `json:"name"`
+	Permissions int      `json:"permissions"`
+	ProbeBucket string   `json:"probe-bucket"`
+	Lifetime    Duration `json:"lifetime"`
+	// PolicyTemplate is a text/template of the IAM policy attached to the
+	// service account, {{.ProbeBucket}} expands to ProbeBucket.
+	PolicyTemplate string `json:"policy-template"`
+}
+
+// DefaultServiceAccountPolicy allows listing every bucket and reading and
+// writing objects in the probe bucket only.
+const DefaultServiceAccountPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": ["s3:ListAllMyBuckets", "s3:GetBucketLocation", "s3:ListBucket"],
+      "Resource": ["arn:aws:s3:::*"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": ["s3:GetObject", "s3:PutObject"],
+      "Resource": ["arn:aws:s3:::{{.ProbeBucket}}/*"]
+    }
+  ]
+}`
+
+func getDefaultServiceAccountConfig() ServiceAccountConfig {
+	return ServiceAccountConfig{
+		Name:           "watchdog",
+		Permissions:    2,
+		ProbeBucket:    "watchdog-probe",
+		Lifetime:       NewDuration(7 * 24 * time.Hour),
+		PolicyTemplate: DefaultServiceAccountPolicy,
+	}
+}
+
+// GetServiceAccountConfig returns the service account settings with defaults
+// filled in for the fields missing from config.json.
+func (controllerConfig *ControllerConfig) GetServiceAccountConfig() ServiceAccountConfig {
+	sac := controllerConfig.ServiceAccount
+	defaults := getDefaultServiceAccountConfig()
+	if sac.Name == "" {
+		sac.Name = defaults.Name
+	}
+	if sac.Permissions == 0 {
+		sac.Permissions = defaults.Permissions
+	}
+	if sac.ProbeBucket == "" {
+		sac.ProbeBucket = defaults.ProbeBucket
+	}
+	if sac.Lifetime.Duration == 0 {
+		sac.Lifetime = defaults.Lifetime
+	}
+	if sac.PolicyTemplate == "" {
+		sac.PolicyTemplate = defaults.PolicyTemplate
+	}
+	return sac
+}
+
+// RenderPolicy expands the policy template.
+func (sac ServiceAccountConfig) RenderPolicy() (string, error) {
+	tmpl, err := template.New("service-account-policy").Parse(sac.PolicyTemplate)
+	if err != nil {
+		return "", err
+	}
+	var policy strings.Builder
+	err = tmpl.Execute(&policy, sac)
+	if err != nil {
+		return "", err
+	}
+	return policy.String(), nil
 }
 
 type S3Info struct {
@@ -43,50 +453,139 @@ type S3Config struct {
 	DNS            string `json:"dns"`
 	BucketSelector int    `json:"bucket-selector"`
 	PageSelector   int    `json:"page-selector"`
+	// EndpointSelector chooses where the S3 probes are sent: "public" (tenant DNS
+	// through the load balancer), "local" (minio port on this node) or "both".
+	EndpointSelector string `json:"endpoint-selector"`
+}
+
+// S3 endpoint selectors
+const (
+	S3EndpointPublic = "public"
+	S3EndpointLocal  = "local"
+	S3EndpointBoth   = "both"
+)
+
+// ProbePublic reports whether the tenant DNS endpoint should be probed.
+// An empty selector keeps the old behaviour of probing the public DNS only.
+func (s3config *S3Config) ProbePublic() bool {
+	switch s3config.EndpointSelector {
+	case S3EndpointLocal:
+		return false
+	default:
+		return true
+	}
+}
+
+// ProbeLocal reports whether the local minio endpoint should be probed.
+func (s3config *S3Config) ProbeLocal() bool {
+	return s3config.EndpointSelector == S3EndpointLocal || s3config.EndpointSelector == S3EndpointBoth
 }
 
 type SystemLevelThreshold struct {
-	HighAvgLoadThreshold     float64
-	HighAvgLoadDuration      time.Duration
-	HighCPUusageThreshold    float64 // Alert if CPU > 80%
-	HighCPUusageDuration     time.Duration
-	HighMemoryUsageThreshold float64 // Alert if RAM usage > 90%
-	HighMemoryUsageDuration  time.Duration
-	HighDiskUsageThreshold   float64
-	HighDiskUsageDuration    time.Duration
+	HighAvgLoadThreshold     float64  `json:"high-avg-load-threshold"`
+	HighAvgLoadDuration      Duration `json:"high-avg-load-duration"`
+	HighCPUusageThreshold    float64  `json:"high-cpu-usage-threshold"` // Alert if CPU > 80%
+	HighCPUusageDuration     Duration `json:"high-cpu-usage-duration"`
+	HighMemoryUsageThreshold float64  `json:"high-memory-usage-threshold"` // Alert if RAM usage > 90%
+	HighMemoryUsageDuration  Duration `json:"high-memory-usage-duration"`
+	HighDiskUsageThreshold   float64  `json:"high-disk-usage-threshold"`
+	HighDiskUsageDuration    Duration `json:"high-disk-usage-duration"`
+}
+
+// TenantRestartThreshold fields missing from config.json keep their default,
+// 0 turns a check off.
+type TenantRestartThreshold struct {
+	RestartDeadline          Duration `json:"restart-deadline"`            // Alert if a requested restart is not done in time
+	RestartInProcessDeadline Duration `json:"restart-in-process-deadline"` // Alert if a tenant stays in RestartInProcess
+	FlapCount                int      `json:"flap-count"`                  // Alert if a tenant restarts this many times
+	FlapWindow               Duration `json:"flap-window"`                 // within this window
+}
+
+func getDefaultTenantRestartThreshold() TenantRestartThreshold {
+	return TenantRestartThreshold{
+		RestartDeadline:          NewDuration(30 * time.Minute),
+		RestartInProcessDeadline: NewDuration(15 * time.Minute),
+		FlapCount:                3,
+		FlapWindow:               NewDuration(1 * time.Hour),
+	}
 }
 
+// LoadConfig reads config.json. A file in an older format is migrated and
+// rewritten in the current one, the original is kept with a .bak suffix.
 func LoadConfig(filePath string) (*Config, error) {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
-
-	var config Config
-	err = json.Unmarshal(data, &config)
+	config, migrated, version, err := parseConfig(data)
 	if err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	if version < CurrentSchemaVersion {
+		if err := rewriteConfig(filePath, data, migrated); err != nil {
+			slog.Error("Failed to rewrite config in the current schema version", "path", filePath, "schema_version", CurrentSchemaVersion, "error", err)
+		} else {
+			slog.Info("Migrated config", "path", filePath, "from_schema_version", version, "to_schema_version", CurrentSchemaVersion)
+		}
+	}
+
+	return config, nil
+}
+
+// ReadConfig reads config.json like LoadConfig but leaves the file as it is.
+// It also returns the schema version the file is in, a file older than
+// CurrentSchemaVersion is migrated in memory only.
+func ReadConfig(filePath string) (*Config, int, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+	config, _, version, err := parseConfig(data)
+	return config, version, err
+}
+
+// parseConfig migrates data and returns the config with the migrated data
+// and the version data was in.
+func parseConfig(data []byte) (*Config, []byte, int, error) {
+	migrated, version, err := migrateConfig(data)
+	if err != nil {
+		return nil, nil, version, err
+	}
+	// defaults for the fields where 0 has a meaning of its own, so missing
+	// ones can be told apart
+	config := Config{TenantRestartThreshold: getDefaultTenantRestartThreshold()}
+	if err := json.Unmarshal(migrated, &config); err != nil {
+		return nil, nil, version, err
+	}
+	return &config, migrated, version, nil
 }
 
 func GetDefaultConfig() *Config {
+	mergeControllerTenants := true
 	return &Config{
-		LogFilePath: "watchdog.log",
+		SchemaVersion: CurrentSchemaVersion,
+		LogFilePath:   "watchdog.log",
+		Logging:       getDefaultLoggingConfig(),
 
 		ApiServerConfig: &ApiServerConfig{
 			NodeId:        "nc1",
 			APIPort:       ":8080",
-			APIServerKey:  "E8AA3FBB0F512B32",
+			APIServerKey:  "",
 			APIServerDNS:  "e2-api.edgedrive.com",
 			TenantListApi: "api/tenant/list",
+
+			TenantListCacheFile:    defaultTenantListCacheFile,
+			MergeControllerTenants: &mergeControllerTenants,
 		},
 		ControllerConfig: &ControllerConfig{
-			AccessKeyDir:         "access-keys",
-			ControllerDNS:        "localhost:44344",
-			AddServiceAccountApi: "admin/v1/add_service_account",
-			GetTenantInfoApi:     "admin/v1/get_tenant_info",
+			AccessKeyDir:           "access-keys",
+			ControllerDNS:          "localhost:44344",
+			AddServiceAccountApi:   "admin/v1/add_service_account",
+			GetTenantInfoApi:       "admin/v1/get_tenant_info",
+			AccessKeyRefreshWindow: NewDuration(24 * time.Hour),
+			ServiceAccount:         getDefaultServiceAccountConfig(),
+			CredentialKeyFile:      defaultCredentialKeyFile,
 		},
 
 		TenantProcessName: "minio",
@@ -107,22 +606,86 @@ func GetDefaultConfig() *Config {
 			"/data4",
 		},
 
-		SystemLevelThreshold: SystemLevelThreshold{
-			HighAvgLoadThreshold:     2.0,
-			HighAvgLoadDuration:      1 * time.Minute,
-			HighCPUusageThreshold:    90, //in percent
-			HighCPUusageDuration:     1 * time.Minute,
-			HighMemoryUsageThreshold: 20, // in percent
-			HighMemoryUsageDuration:  1 * time.Minute,
-			HighDiskUsageThreshold:   50, //in %
-			HighDiskUsageDuration:    1 * time.Minute,
+		SystemLevelThreshold:           getDefaultSystemLevelThreshold(),
+		TenantDriftGracePeriod:         NewDuration(30 * time.Minute),
+		TenantInventoryRefreshInterval: NewDuration(5 * time.Minute),
+		ShutdownTimeout:                NewDuration(defaultShutdownTimeout),
+		HTTPAPI: HTTPAPIConfig{
+			Auth: APIAuthConfig{MaxClockSkew: NewDuration(defaultMaxClockSkew)},
+		},
+		HTTPClient: getDefaultHTTPClientConfig(),
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold: 5,
+			CoolDown:         NewDuration(1 * time.Minute),
 		},
+		TenantRestartThreshold: getDefaultTenantRestartThreshold(),
 		//TenatS3ConfigMap: make(map[string]*S3Config),
 	}
 }
 
+// LoadKeyring loads the keyring for decrypting API server and controller
+// payloads. Without a keyring file or environment variable the APIServerKey
+// is used as the only key, as older versions did.
+func (config *Config) LoadKeyring() (*cryption.Keyring, error) {
+	keyring, err := cryption.LoadKeyring(config.KeyringFile, "WATCHDOG_KEYRING")
+	if err != nil {
+		return nil, err
+	}
+	if keyring.Len() == 0 && config.ApiServerConfig != nil && config.ApiServerConfig.APIServerKey != "" {
+		err = keyring.AddKey("api-server-key", []byte(config.ApiServerConfig.APIServerKey))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if keyring.Len() == 0 {
+		return nil, fmt.Errorf("no keys configured, set keyring-file, WATCHDOG_KEYRING or api-server-key")
+	}
+	return keyring, nil
+}
+
+func getDefaultSystemLevelThreshold() SystemLevelThreshold {
+	return SystemLevelThreshold{
+		HighAvgLoadThreshold:     2.0,
+		HighAvgLoadDuration:      NewDuration(1 * time.Minute),
+		HighCPUusageThreshold:    90, //in percent
+		HighCPUusageDuration:     NewDuration(1 * time.Minute),
+		HighMemoryUsageThreshold: 20, // in percent
+		HighMemoryUsageDuration:  NewDuration(1 * time.Minute),
+		HighDiskUsageThreshold:   50, //in %
+		HighDiskUsageDuration:    NewDuration(1 * time.Minute),
+	}
+}
+
+// GetSystemLevelThreshold returns the system thresholds with defaults filled
+// in for the fields missing from config.json.
 func (config *Config) GetSystemLevelThreshold() SystemLevelThreshold {
-	return config.SystemLevelThreshold
+	slt := config.SystemLevelThreshold
+	defaults := getDefaultSystemLevelThreshold()
+	if slt.HighAvgLoadThreshold == 0 {
+		slt.HighAvgLoadThreshold = defaults.HighAvgLoadThreshold
+	}
+	if slt.HighAvgLoadDuration.Duration == 0 {
+		slt.HighAvgLoadDuration = defaults.HighAvgLoadDuration
+	}
+	if slt.HighCPUusageThreshold == 0 {
+		slt.HighCPUusageThreshold = defaults.HighCPUusageThreshold
+	}
+	if slt.HighCPUusageDuration.Duration == 0 {
+		slt.HighCPUusageDuration = defaults.HighCPUusageDuration
+	}
+	if slt.HighMemoryUsageThreshold == 0 {
+		slt.HighMemoryUsageThreshold = defaults.HighMemoryUsageThreshold
+	}
+	if slt.HighMemoryUsageDuration.Duration == 0 {
+		slt.HighMemoryUsageDuration = defaults.HighMemoryUsageDuration
+	}
+	if slt.HighDiskUsageThreshold == 0 {
+		slt.HighDiskUsageThreshold = defaults.HighDiskUsageThreshold
+	}
+	if slt.HighDiskUsageDuration.Duration == 0 {
+		slt.HighDiskUsageDuration = defaults.HighDiskUsageDuration
+	}
+	return slt
 }
 func (config *Config) SetSystemLevelThreshold(sysThreshold SystemLevelThreshold) {
 	config.SystemLevelThreshold = sysThreshold
@@ -131,8 +694,9 @@ func (config *Config) GetProcessToMonitor() []string {
 	return config.MonitoredProcesses
 }
 
+// AddProcessToMonitor changes the config it is called on, to change the
+// running configuration call it from ConfigStore.Update, which validates it.
 func (config *Config) AddProcessToMonitor(processName string) {
-	//TODO: validate process name
 	config.MonitoredProcesses = append(config.MonitoredProcesses, processName)
 }
 
@@ -140,20 +704,22 @@ func (config *Config) GetDisksToMonitor() []string {
 	return config.MonitoredDisks
 }
 
+// AddDiskToMonitor changes the config it is called on, to change the
+// running configuration call it from ConfigStore.Update, which validates it.
 func (config *Config) AddDiskToMonitor(diskName string) {
-	//TODO: validate disk name
 	config.MonitoredDisks = append(config.MonitoredDisks, diskName)
 }
 
 func (config *Config) AddDefaultS3Config(tenant dto.Tenant) (*S3Config, error) {
 	s3config := &S3Config{
-		DNS:            tenant.DNS,
-		BucketSelector: 1,
-		PageSelector:   1,
+		DNS:              tenant.DNS,
+		BucketSelector:   1,
+		PageSelector:     1,
+		EndpointSelector: S3EndpointBoth,
 	}
 	s3configDir := filepath.Join(config.ControllerConfig.AccessKeyDir, tenant.DNS)
 	if _, err := os.Stat(s3configDir); os.IsNotExist(err) {
-		err := os.MkdirAll(s3configDir, os.ModePerm)
+		err := os.MkdirAll(s3configDir, 0700)
 		if err != nil {
 			//log.Fatalf("Failed to create access key directory: %v", err)
 			return nil, err
@@ -185,7 +751,7 @@ func (config *Config) GetS3Config(tenant dto.Tenant) (*S3Config, error) {
 	data, err := os.ReadFile(s3configPath)
 	if err != nil {
 		// If the file does not exist, create a default S3Config
-		log.Printf("S3 configuration not available for tenant %s, adding default configuration", tenant.DNS)
+		slog.Info("S3 configuration not available, using the default", "tenant", tenant.DNS)
 
 		return config.AddDefaultS3Config(tenant)
 	}
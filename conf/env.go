@@ -0,0 +1,191 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the environment variables that override config.json. The
+// variable of a field is its JSON path in upper case with "-" and "." turned
+// into "_", e.g. WATCHDOG_API_SERVER_CONFIG_API_SERVER_KEY overrides
+// api-server-config.api-server-key. Lists of strings are comma separated,
+// lists of objects and maps are given as JSON, e.g.
+// WATCHDOG_HTTP_API_AUTH_TOKENS='[{"name":"ops","token":"...","scope":"read"}]'.
+//
+// Settings are applied in this order, a later one wins:
+//  1. config.json
+//  2. WATCHDOG_* environment variables
+//  3. command-line flags
+//
+// Settings missing from all of them keep the default their getter fills in,
+// config.json only holds every built-in default when it was created by the
+// watchdog.
+const EnvPrefix = "WATCHDOG_"
+
+var (
+	durationType = reflect.TypeOf(Duration{})
+	byteSizeType = reflect.TypeOf(ByteSize(0))
+)
+
+// ApplyEnv overrides the fields that have a WATCHDOG_* variable in environ,
+// given in os.Environ form. Variables that match no field are ignored.
+func (config *Config) ApplyEnv(environ []string) error {
+	env := make(map[string]string)
+	for _, entry := range environ {
+		name, value, found := strings.Cut(entry, "=")
+		if found && strings.HasPrefix(name, EnvPrefix) {
+			env[name] = value
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+
+	var errs ValidationErrors
+	applyEnv(reflect.ValueOf(config).Elem(), EnvPrefix, "", env, &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func applyEnv(v reflect.Value, prefix, path string, env map[string]string, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := strings.Split(field.Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		fv := v.Field(i)
+
+		switch {
+		case field.Type == durationType || field.Type == byteSizeType:
+		case field.Type.Kind() == reflect.Struct:
+			applyEnv(fv, name+"_", fieldPath, env, errs)
+			continue
+		case field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct:
+			if !hasEnvWithPrefix(env, name+"_") {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(field.Type.Elem()))
+			}
+			applyEnv(fv.Elem(), name+"_", fieldPath, env, errs)
+			continue
+		}
+
+		value, found := env[name]
+		if !found {
+			continue
+		}
+		if err := setFromEnv(fv, value); err != nil {
+			*errs = append(*errs, ValidationError{Path: fieldPath, Message: fmt.Sprintf("%s: %v", name, err)})
+		}
+	}
+}
+
+func hasEnvWithPrefix(env map[string]string, prefix string) bool {
+	for name := range env {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func setFromEnv(fv reflect.Value, value string) error {
+	if fv.Type() == durationType || fv.Type() == byteSizeType {
+		return json.Unmarshal([]byte(strconv.Quote(value)), fv.Addr().Interface())
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Ptr:
+		elem := reflect.New(fv.Type().Elem())
+		if err := setFromEnv(elem.Elem(), value); err != nil {
+			return err
+		}
+		fv.Set(elem)
+	case reflect.Map:
+		return setFromJSON(fv, value)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return setFromJSON(fv, value)
+		}
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %v", fv.Type())
+	}
+	return nil
+}
+
+// setFromJSON replaces fv with value decoded as JSON, which must be an
+// object or array like the field in config.json.
+func setFromJSON(fv reflect.Value, value string) error {
+	decoded := reflect.New(fv.Type())
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(decoded.Interface()); err != nil {
+		return fmt.Errorf("want JSON like in config.json: %v", err)
+	}
+	fv.Set(decoded.Elem())
+	return nil
+}
+
+// ResolvePaths makes the relative file and directory paths of the config
+// relative to dataDir instead of the working directory.
+func (config *Config) ResolvePaths(dataDir string) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dataDir, path)
+	}
+	config.LogFilePath = resolve(config.LogFilePath)
+	config.KeyringFile = resolve(config.KeyringFile)
+	config.HTTPAPI.TLS.CertFile = resolve(config.HTTPAPI.TLS.CertFile)
+	config.HTTPAPI.TLS.KeyFile = resolve(config.HTTPAPI.TLS.KeyFile)
+	config.HTTPAPI.TLS.ClientCAFile = resolve(config.HTTPAPI.TLS.ClientCAFile)
+	config.HTTPAPI.Auth.CredentialsFile = resolve(config.HTTPAPI.Auth.CredentialsFile)
+	if config.ApiServerConfig != nil {
+		config.ApiServerConfig.TenantListCacheFile = resolve(config.ApiServerConfig.GetTenantListCacheFile())
+	}
+	if config.ControllerConfig != nil {
+		config.ControllerConfig.AccessKeyDir = resolve(config.ControllerConfig.AccessKeyDir)
+		config.ControllerConfig.CredentialKeyFile = resolve(config.ControllerConfig.GetCredentialKeyFile())
+	}
+}
@@ -0,0 +1,115 @@
+package conf
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	config := &Config{LogFilePath: "watchdog.log"}
+	err := config.ApplyEnv([]string{
+		"WATCHDOG_LOG_FILE_PATH=/var/log/watchdog.log",
+		"WATCHDOG_TENANT_DRIFT_GRACE_PERIOD=10m",
+		"WATCHDOG_LOGGING_MAX_AGE=7d",
+		"WATCHDOG_LOGGING_MAX_SIZE=512MiB",
+		"WATCHDOG_LOGGING_MAX_BACKUPS=5",
+		"WATCHDOG_SYSTEM_LEVEL_THRESHOLD_HIGH_CPU_USAGE_THRESHOLD=85.5",
+		"WATCHDOG_MONITORED_DISKS=/, /data1,,",
+		"WATCHDOG_API_SERVER_CONFIG_NODE_ID=nc7",
+		"WATCHDOG_API_SERVER_CONFIG_MERGE_CONTROLLER_TENANTS=false",
+		`WATCHDOG_HTTP_API_AUTH_TOKENS=[{"name":"ops","token":"secret","scope":"read"}]`,
+		`WATCHDOG_SCHEDULES={"tenant-s3-stats":{"interval":"15m"}}`,
+		"WATCHDOG_NOT_A_FIELD=ignored",
+		"PATH=/usr/bin",
+	})
+	if err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+
+	if config.LogFilePath != "/var/log/watchdog.log" {
+		t.Errorf("log-file-path = %q", config.LogFilePath)
+	}
+	if config.TenantDriftGracePeriod.Duration != 10*time.Minute {
+		t.Errorf("tenant-drift-grace-period = %v, want 10m", config.TenantDriftGracePeriod)
+	}
+	if config.Logging.MaxAge.Duration != 7*24*time.Hour {
+		t.Errorf("logging.max-age = %v, want 7d", config.Logging.MaxAge)
+	}
+	if config.Logging.MaxSize != 512<<20 {
+		t.Errorf("logging.max-size = %v, want 512MiB", config.Logging.MaxSize)
+	}
+	if config.Logging.MaxBackups != 5 {
+		t.Errorf("logging.max-backups = %d, want 5", config.Logging.MaxBackups)
+	}
+	if config.SystemLevelThreshold.HighCPUusageThreshold != 85.5 {
+		t.Errorf("system-level-threshold.high-cpu-usage-threshold = %v, want 85.5", config.SystemLevelThreshold.HighCPUusageThreshold)
+	}
+	if want := []string{"/", "/data1"}; !reflect.DeepEqual(config.MonitoredDisks, want) {
+		t.Errorf("monitored-disks = %q, want %q", config.MonitoredDisks, want)
+	}
+	// the section is created for its variables, the others stay nil
+	if config.ApiServerConfig == nil || config.ApiServerConfig.NodeId != "nc7" {
+		t.Fatalf("api-server-config = %+v, want node-id nc7", config.ApiServerConfig)
+	}
+	if config.ApiServerConfig.GetMergeControllerTenants() {
+		t.Error("api-server-config.merge-controller-tenants = true, want false")
+	}
+	if config.ControllerConfig != nil {
+		t.Errorf("controller-config = %+v, want nil", config.ControllerConfig)
+	}
+	if want := []APIToken{{Name: "ops", Token: "secret", Scope: "read"}}; !reflect.DeepEqual(config.HTTPAPI.Auth.Tokens, want) {
+		t.Errorf("http-api.auth.tokens = %+v, want %+v", config.HTTPAPI.Auth.Tokens, want)
+	}
+	if schedule := config.Schedules["tenant-s3-stats"]; schedule.Interval.Duration != 15*time.Minute {
+		t.Errorf("schedules = %+v, want tenant-s3-stats every 15m", config.Schedules)
+	}
+}
+
+func TestApplyEnvSizeAsNumber(t *testing.T) {
+	config := &Config{}
+	if err := config.ApplyEnv([]string{"WATCHDOG_LOGGING_MAX_SIZE=1048576"}); err != nil {
+		t.Fatalf("ApplyEnv: %v", err)
+	}
+	if config.Logging.MaxSize != 1<<20 {
+		t.Errorf("logging.max-size = %v, want 1MiB", config.Logging.MaxSize)
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	config := &Config{}
+	err := config.ApplyEnv([]string{
+		// a number is not read as nanoseconds as in config.json
+		"WATCHDOG_TENANT_DRIFT_GRACE_PERIOD=60000000000",
+		"WATCHDOG_LOGGING_MAX_SIZE=-1",
+		"WATCHDOG_LOGGING_MAX_BACKUPS=five",
+		"WATCHDOG_API_SERVER_CONFIG_MERGE_CONTROLLER_TENANTS=maybe",
+		`WATCHDOG_HTTP_API_AUTH_TOKENS=[{"name":"ops","secret":"x"}]`,
+		"WATCHDOG_HTTP_API_AUTH_HMAC_KEYS=key1",
+	})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ApplyEnv = %v, want ValidationErrors", err)
+	}
+	var paths []string
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+		if !strings.HasPrefix(e.Message, EnvPrefix) {
+			t.Errorf("%s: message %q does not name the variable", e.Path, e.Message)
+		}
+	}
+	// in the order of the fields
+	want := []string{
+		"logging.max-size",
+		"logging.max-backups",
+		"api-server-config.merge-controller-tenants",
+		"tenant-drift-grace-period",
+		"http-api.auth.tokens",
+		"http-api.auth.hmac-keys",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("errors at %q, want %q", paths, want)
+	}
+}
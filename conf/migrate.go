@@ -0,0 +1,169 @@
+package conf
+
+import (
+	"ChintuIdrive/storage-node-watchdog/fileutil"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// legacyKeys maps the Go field names schema version 0 used as keys of the
+// threshold sections to their kebab-case keys.
+var legacyKeys = map[string]map[string]string{
+	"system-level-threshold": {
+		"HighAvgLoadThreshold":     "high-avg-load-threshold",
+		"HighAvgLoadDuration":      "high-avg-load-duration",
+		"HighCPUusageThreshold":    "high-cpu-usage-threshold",
+		"HighCPUusageDuration":     "high-cpu-usage-duration",
+		"HighMemoryUsageThreshold": "high-memory-usage-threshold",
+		"HighMemoryUsageDuration":  "high-memory-usage-duration",
+		"HighDiskUsageThreshold":   "high-disk-usage-threshold",
+		"HighDiskUsageDuration":    "high-disk-usage-duration",
+	},
+	"tenant-restart-threshold": {
+		"RestartDeadline":          "restart-deadline",
+		"RestartInProcessDeadline": "restart-in-process-deadline",
+		"FlapCount":                "flap-count",
+		"FlapWindow":               "flap-window",
+	},
+}
+
+// migrateConfig converts config.json data to the current schema version and
+// returns it with the version it was in. Only the keys and values that
+// changed are rewritten, fields missing from the file stay missing.
+func migrateConfig(data []byte) ([]byte, int, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, 0, err
+	}
+
+	version := 0
+	if v, found := raw["schema-version"]; found {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, 0, fmt.Errorf("schema-version must be an integer, got %s", v)
+		}
+	}
+	if version > CurrentSchemaVersion {
+		return nil, version, fmt.Errorf("schema-version %d is newer than the supported %d", version, CurrentSchemaVersion)
+	}
+	if version == CurrentSchemaVersion {
+		return data, version, nil
+	}
+
+	for section, keys := range legacyKeys {
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(raw[section], &values); err != nil || values == nil {
+			// missing or not an object, unmarshalling the config reports the latter
+			continue
+		}
+		for oldKey, newKey := range keys {
+			if value, found := values[oldKey]; found {
+				delete(values, oldKey)
+				values[newKey] = value
+			}
+		}
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return nil, version, err
+		}
+		raw[section] = encoded
+	}
+	if err := convertUnits(raw, reflect.TypeOf(Config{})); err != nil {
+		return nil, version, err
+	}
+	raw["schema-version"] = json.RawMessage(strconv.Itoa(CurrentSchemaVersion))
+
+	migrated, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, version, err
+	}
+	return migrated, version, nil
+}
+
+// convertUnits rewrites the durations and sizes schema version 0 wrote as
+// numbers in the fields of an object of type t in their string form, e.g.
+// 60000000000 as "1m".
+func convertUnits(fields map[string]json.RawMessage, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		value, found := fields[key]
+		if key == "" || key == "-" || !found {
+			continue
+		}
+		converted, err := convertValueUnits(value, t.Field(i).Type)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		fields[key] = converted
+	}
+	return nil
+}
+
+func convertValueUnits(value json.RawMessage, t reflect.Type) (json.RawMessage, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType || t == byteSizeType:
+		if len(value) == 0 || (value[0] != '-' && (value[0] < '0' || value[0] > '9')) {
+			return value, nil
+		}
+		v := reflect.New(t)
+		if err := json.Unmarshal(value, v.Interface()); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v.Interface())
+	case t.Kind() == reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(value, &fields); err != nil || fields == nil {
+			return value, nil
+		}
+		if err := convertUnits(fields, t); err != nil {
+			return nil, err
+		}
+		return json.Marshal(fields)
+	case t.Kind() == reflect.Map:
+		var entries map[string]json.RawMessage
+		if err := json.Unmarshal(value, &entries); err != nil || entries == nil {
+			return value, nil
+		}
+		for key, entry := range entries {
+			converted, err := convertValueUnits(entry, t.Elem())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+			entries[key] = converted
+		}
+		return json.Marshal(entries)
+	case t.Kind() == reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(value, &items); err != nil || items == nil {
+			return value, nil
+		}
+		for i, item := range items {
+			converted, err := convertValueUnits(item, t.Elem())
+			if err != nil {
+				return nil, fmt.Errorf("%d: %v", i, err)
+			}
+			items[i] = converted
+		}
+		return json.Marshal(items)
+	}
+	return value, nil
+}
+
+// rewriteConfig saves the migrated data in place of the original, which is
+// kept next to it with a .bak suffix.
+func rewriteConfig(filePath string, original, migrated []byte) error {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(filePath+".bak", original, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(filePath, migrated, info.Mode().Perm())
+}
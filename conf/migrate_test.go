@@ -0,0 +1,187 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testdata/config_v0.json is config.json as schema version 0 wrote it, with
+// the Go field names as threshold keys and durations in nanoseconds.
+func readV0Config(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "config_v0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func objectKeys(t *testing.T, data []byte) []string {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("%s: %v", data, err)
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestMigrateConfigV0(t *testing.T) {
+	original := readV0Config(t)
+	migrated, version, err := migrateConfig(original)
+	if err != nil {
+		t.Fatalf("migrateConfig: %v", err)
+	}
+	if version != 0 {
+		t.Fatalf("version = %d, want 0", version)
+	}
+
+	// only schema-version is added, the defaults of the new fields are not
+	// written to the file
+	wantKeys := append(objectKeys(t, original), "schema-version")
+	sort.Strings(wantKeys)
+	if keys := objectKeys(t, migrated); !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("keys = %v, want %v", keys, wantKeys)
+	}
+
+	var file struct {
+		SchemaVersion        int               `json:"schema-version"`
+		SystemLevelThreshold map[string]any    `json:"system-level-threshold"`
+		ApiServerConfig      map[string]string `json:"api-server-config"`
+	}
+	if err := json.Unmarshal(migrated, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("schema-version = %d, want %d", file.SchemaVersion, CurrentSchemaVersion)
+	}
+	wantThreshold := map[string]any{
+		"high-avg-load-threshold":     2.0,
+		"high-avg-load-duration":      "1m",
+		"high-cpu-usage-threshold":    90.0,
+		"high-cpu-usage-duration":     "1m",
+		"high-memory-usage-threshold": 20.0,
+		"high-memory-usage-duration":  "1m",
+		"high-disk-usage-threshold":   50.0,
+		"high-disk-usage-duration":    "1m",
+	}
+	if !reflect.DeepEqual(file.SystemLevelThreshold, wantThreshold) {
+		t.Errorf("system-level-threshold = %v, want %v", file.SystemLevelThreshold, wantThreshold)
+	}
+	if file.ApiServerConfig["api-server-key"] != "E8AA3FBB0F512B32" {
+		t.Errorf("api-server-config = %v, want it unchanged", file.ApiServerConfig)
+	}
+
+	config, _, _, err := parseConfig(original)
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	slt := config.SystemLevelThreshold
+	if slt.HighCPUusageThreshold != 90 || slt.HighDiskUsageDuration.Duration != time.Minute {
+		t.Errorf("system-level-threshold = %+v", slt)
+	}
+	if config.TenantRestartThreshold != getDefaultTenantRestartThreshold() {
+		t.Errorf("tenant-restart-threshold = %+v, want the defaults", config.TenantRestartThreshold)
+	}
+}
+
+func TestMigrateConfigCurrentVersion(t *testing.T) {
+	data := []byte(`{"schema-version": 1, "tenant-drift-grace-period": 60000000000}`)
+	migrated, version, err := migrateConfig(data)
+	if err != nil {
+		t.Fatalf("migrateConfig: %v", err)
+	}
+	if version != CurrentSchemaVersion || !bytes.Equal(migrated, data) {
+		t.Errorf("migrateConfig = %s, %d, want the data unchanged", migrated, version)
+	}
+
+	if _, _, err := migrateConfig([]byte(`{"schema-version": 2}`)); err == nil {
+		t.Error("migrateConfig of a newer schema version succeeded")
+	}
+	if _, _, err := migrateConfig([]byte(`{"schema-version": "1"}`)); err == nil {
+		t.Error("migrateConfig of a string schema version succeeded")
+	}
+}
+
+func TestConvertValueUnits(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		t     reflect.Type
+		want  string
+	}{
+		{"duration number", `60000000000`, durationType, `"1m"`},
+		{"duration string", `"90s"`, durationType, `"90s"`},
+		{"duration days", `"7d"`, durationType, `"7d"`},
+		{"size number", `1048576`, byteSizeType, `"1MiB"`},
+		{"size string", `"512MB"`, byteSizeType, `"512MB"`},
+		{"not a unit", `42`, reflect.TypeOf(0), `42`},
+		{"struct", `{"max-size": 1024, "rotate-every": 3600000000000, "level": "info"}`, reflect.TypeOf(LoggingConfig{}),
+			`{"level":"info","max-size":"1KiB","rotate-every":"1h"}`},
+		{"map", `{"tenant-s3-stats": {"interval": 300000000000}}`, reflect.TypeOf(map[string]ScheduleConfig{}),
+			`{"tenant-s3-stats":{"interval":"5m"}}`},
+		{"pointer", `{"lifetime": 86400000000000}`, reflect.TypeOf(&ServiceAccountConfig{}), `{"lifetime":"24h"}`},
+		{"null", `null`, reflect.TypeOf(LoggingConfig{}), `null`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			converted, err := convertValueUnits(json.RawMessage(test.value), test.t)
+			if err != nil {
+				t.Fatalf("convertValueUnits: %v", err)
+			}
+			if string(converted) != test.want {
+				t.Errorf("convertValueUnits = %s, want %s", converted, test.want)
+			}
+		})
+	}
+
+	if _, err := convertValueUnits(json.RawMessage(`1e400`), durationType); err == nil {
+		t.Error("convertValueUnits of an out of range duration succeeded")
+	}
+}
+
+func TestLoadConfigRewritesOldVersion(t *testing.T) {
+	original := readV0Config(t)
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, original, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, version, err := ReadConfig(path); err != nil || version != 0 {
+		t.Fatalf("ReadConfig = %d, %v, want version 0", version, err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, original) {
+		t.Fatal("ReadConfig changed the file")
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Fatalf("ReadConfig wrote a backup: %v", err)
+	}
+
+	if _, err := LoadConfig(path); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || !bytes.Equal(backup, original) {
+		t.Errorf("backup = %s, %v, want the original", backup, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want the original 0600", info.Mode().Perm())
+	}
+	if _, version, err := ReadConfig(path); err != nil || version != CurrentSchemaVersion {
+		t.Errorf("ReadConfig after LoadConfig = %d, %v, want version %d", version, err, CurrentSchemaVersion)
+	}
+}
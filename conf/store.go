@@ -0,0 +1,166 @@
+package conf
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ConfigStore holds the current configuration. Readers get an immutable
+// snapshot from Get, a reload validates a new snapshot and swaps it in,
+// keeping the previous one if it is invalid. The environment and flag
+// overrides are applied to every snapshot but never saved to the file.
+type ConfigStore struct {
+	path        string
+	readOnly    bool
+	overrides   func(config *Config) error
+	current     atomic.Pointer[Config]
+	lock        sync.Mutex // serializes reloads
+	modTime     time.Time  // of the file the current config was read from
+	subscribers []func(old, new *Config)
+}
+
+// NewConfigStore loads the config file and applies the overrides, which may
+// be nil. The result is not validated.
+func NewConfigStore(path string, overrides func(config *Config) error) (*ConfigStore, error) {
+	cs := &ConfigStore{path: path, overrides: overrides}
+	config, err := cs.load()
+	if err != nil {
+		return nil, err
+	}
+	cs.current.Store(config)
+	return cs, nil
+}
+
+// NewReadOnlyConfigStore is NewConfigStore for a store that never writes the
+// config file, which is read with ReadConfig.
+func NewReadOnlyConfigStore(path string, overrides func(config *Config) error) (*ConfigStore, error) {
+	cs := &ConfigStore{path: path, readOnly: true, overrides: overrides}
+	config, err := cs.load()
+	if err != nil {
+		return nil, err
+	}
+	cs.current.Store(config)
+	return cs, nil
+}
+
+// Path returns the config file.
+func (cs *ConfigStore) Path() string {
+	return cs.path
+}
+
+// load reads the file and applies the overrides, it must be called with the
+// lock held or before the store is shared.
+func (cs *ConfigStore) load() (*Config, error) {
+	var config *Config
+	var err error
+	if cs.readOnly {
+		config, _, err = ReadConfig(cs.path)
+	} else {
+		config, err = LoadConfig(cs.path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// stat after loading, the load may have rewritten an old format file
+	if info, err := os.Stat(cs.path); err == nil {
+		cs.modTime = info.ModTime()
+	}
+	if err := cs.applyOverrides(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// applyOverrides applies the overrides, then loads the HTTP API credentials
+// file they may have set or moved.
+func (cs *ConfigStore) applyOverrides(config *Config) error {
+	if cs.overrides != nil {
+		if err := cs.overrides(config); err != nil {
+			return err
+		}
+	}
+	return config.LoadAPICredentials()
+}
+
+// Get returns the current configuration. It must not be modified.
+func (cs *ConfigStore) Get() *Config {
+	return cs.current.Load()
+}
+
+// Subscribe registers a handler called after each successful reload.
+func (cs *ConfigStore) Subscribe(handler func(old, new *Config)) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.subscribers = append(cs.subscribers, handler)
+}
+
+// Reload reads the config file again and swaps it in if it is valid.
+func (cs *ConfigStore) Reload() error {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+
+	config, err := cs.load()
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	cs.swap(config)
+	return nil
+}
+
+// swap must be called with the lock held.
+func (cs *ConfigStore) swap(config *Config) {
+	old := cs.current.Swap(config)
+	for _, handler := range cs.subscribers {
+		handler(old, config)
+	}
+}
+
+// Watch reloads the configuration on SIGHUP and when the file changes,
+// checking its modification time every pollInterval, until ctx is done.
+func (cs *ConfigStore) Watch(ctx context.Context, pollInterval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("Received SIGHUP, reloading config", "path", cs.path)
+		case <-ticker.C:
+			if !cs.fileChanged() {
+				continue
+			}
+			slog.Info("Config file changed, reloading", "path", cs.path)
+		}
+		if err := cs.Reload(); err != nil {
+			alert.RaiseUntilResolved("config-reload", "Failed to reload %s, keeping the previous configuration: %v", cs.path, err)
+			continue
+		}
+		slog.Info("Reloaded config", "path", cs.path)
+		alert.Resolve("config-reload")
+	}
+}
+
+func (cs *ConfigStore) fileChanged() bool {
+	info, err := os.Stat(cs.path)
+	if err != nil {
+		return false
+	}
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	return !info.ModTime().Equal(cs.modTime)
+}
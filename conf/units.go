@@ -0,0 +1,140 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration written in config.json as a string like "5m"
+// or "7d". A number is read as nanoseconds, the format of older versions.
+type Duration struct {
+	time.Duration
+}
+
+func NewDuration(d time.Duration) Duration {
+	return Duration{Duration: d}
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(formatDuration(d.Duration))
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		d.Duration = time.Duration(v)
+		return nil
+	case string:
+		parsed, err := ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		d.Duration = parsed
+		return nil
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+}
+
+// ParseDuration parses a time.ParseDuration string, which may also use "d"
+// for days.
+func ParseDuration(s string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// formatDuration drops the zero minutes and seconds time.Duration.String
+// adds, "1h0m0s" becomes "1h".
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// ByteSize is a number of bytes written in config.json as a string like
+// "512MiB" or "1GB". A number is read as bytes.
+type ByteSize int64
+
+var byteSizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3},
+	{"B", 1},
+}
+
+func (b ByteSize) String() string {
+	for _, unit := range byteSizeUnits[:4] {
+		if b != 0 && int64(b)%unit.size == 0 {
+			return fmt.Sprintf("%d%s", int64(b)/unit.size, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", int64(b))
+}
+
+func (b ByteSize) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*b = ByteSize(v)
+		return nil
+	case string:
+		parsed, err := ParseByteSize(v)
+		if err != nil {
+			return err
+		}
+		*b = parsed
+		return nil
+	default:
+		return fmt.Errorf("invalid size %s", data)
+	}
+}
+
+// ParseByteSize parses a size with a binary (KiB) or decimal (KB) unit.
+func ParseByteSize(s string) (ByteSize, error) {
+	trimmed := strings.TrimSpace(s)
+	for _, unit := range byteSizeUnits {
+		if number, found := strings.CutSuffix(trimmed, unit.suffix); found {
+			n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid size %q", s)
+			}
+			return ByteSize(n * float64(unit.size)), nil
+		}
+	}
+	n, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return ByteSize(n), nil
+}
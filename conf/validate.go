@@ -0,0 +1,337 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidationError is a problem with one config field, Path is the JSON path
+// of the field in config.json.
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors holds every problem found by Validate.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("invalid configuration:\n  %s", strings.Join(messages, "\n  "))
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) addf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf(path, "must not be empty")
+	}
+}
+
+func (v *validator) percent(path string, value float64) {
+	if value <= 0 || value > 100 {
+		v.addf(path, "must be a percentage above 0 and at most 100, got %v", value)
+	}
+}
+
+func (v *validator) positiveDuration(path string, value Duration) {
+	if value.Duration <= 0 {
+		v.addf(path, "must be a positive duration, got %v", value)
+	}
+}
+
+// optionalDuration checks a duration for which zero means the default.
+func (v *validator) optionalDuration(path string, value Duration) {
+	if value.Duration < 0 {
+		v.addf(path, "must not be negative, got %v", value)
+	}
+}
+
+func (v *validator) nonNegative(path string, value int) {
+	if value < 0 {
+		v.addf(path, "must not be negative, got %d", value)
+	}
+}
+
+// host checks a host or host:port without scheme or path.
+func (v *validator) host(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf(path, "must not be empty")
+		return
+	}
+	if strings.Contains(value, "://") || strings.ContainsAny(value, "/ ") {
+		v.addf(path, "must be a host name with an optional port, without scheme or path, got %q", value)
+	}
+}
+
+func (v *validator) listenAddress(path, value string) {
+	_, port, err := net.SplitHostPort(value)
+	if err != nil {
+		v.addf(path, "must be [host]:port, got %q", value)
+		return
+	}
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		v.addf(path, "port must be between 1 and 65535, got %q", port)
+	}
+}
+
+func (v *validator) fileExists(path, file string) {
+	if _, err := os.Stat(file); err != nil {
+		v.addf(path, "%v", err)
+	}
+}
+
+// parentDirExists checks that a file can be created at the given path.
+func (v *validator) parentDirExists(path, file string) {
+	dir := filepath.Dir(file)
+	info, err := os.Stat(dir)
+	if err != nil {
+		v.addf(path, "directory of %s: %v", file, err)
+		return
+	}
+	if !info.IsDir() {
+		v.addf(path, "%s is not a directory", dir)
+	}
+}
+
+// Validate checks every field of the configuration and returns all the
+// problems found as ValidationErrors, or nil if there are none.
+func (config *Config) Validate() error {
+	v := &validator{}
+
+	if config.LogFilePath == "" {
+		v.addf("log-file-path", "must not be empty")
+	} else {
+		v.parentDirExists("log-file-path", config.LogFilePath)
+	}
+	config.validateLogging(v)
+	config.validateHTTPAPI(v)
+	v.required("tenant-process-name", config.TenantProcessName)
+
+	seenProcesses := make(map[string]bool)
+	for i, process := range config.MonitoredProcesses {
+		path := fmt.Sprintf("monitored-processes[%d]", i)
+		v.required(path, process)
+		if seenProcesses[process] {
+			v.addf(path, "%q is listed more than once", process)
+		}
+		seenProcesses[process] = true
+	}
+	for i, disk := range config.MonitoredDisks {
+		path := fmt.Sprintf("monitored-disks[%d]", i)
+		info, err := os.Stat(disk)
+		if err != nil {
+			v.addf(path, "%v", err)
+		} else if !info.IsDir() {
+			v.addf(path, "%s is not a mount point directory", disk)
+		}
+	}
+
+	config.validateApiServerConfig(v)
+	config.validateControllerConfig(v)
+	config.validateThresholds(v)
+
+	if config.KeyringFile != "" {
+		v.fileExists("keyring-file", config.KeyringFile)
+	}
+
+	hcc := config.GetHTTPClientConfig()
+	v.optionalDuration("http-client.timeout", config.HTTPClient.Timeout)
+	v.optionalDuration("http-client.initial-backoff", config.HTTPClient.InitialBackoff)
+	v.optionalDuration("http-client.max-backoff", config.HTTPClient.MaxBackoff)
+	v.optionalDuration("http-client.idle-conn-timeout", config.HTTPClient.IdleConnTimeout)
+	v.nonNegative("http-client.max-idle-conns", config.HTTPClient.MaxIdleConns)
+	if hcc.MaxBackoff.Duration < hcc.InitialBackoff.Duration {
+		v.addf("http-client.max-backoff", "must not be below initial-backoff %v, got %v", hcc.InitialBackoff, hcc.MaxBackoff)
+	}
+
+	v.nonNegative("circuit-breaker.failure-threshold", config.CircuitBreaker.FailureThreshold)
+	v.optionalDuration("circuit-breaker.cool-down", config.CircuitBreaker.CoolDown)
+	v.optionalDuration("shutdown-timeout", config.ShutdownTimeout)
+	names := make([]string, 0, len(config.Schedules))
+	for name := range config.Schedules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		schedule := config.Schedules[name]
+		path := "schedules." + name
+		v.optionalDuration(path+".interval", schedule.Interval)
+		v.optionalDuration(path+".initial-delay", schedule.InitialDelay)
+		v.optionalDuration(path+".jitter", schedule.Jitter)
+		v.optionalDuration(path+".timeout", schedule.Timeout)
+		if schedule.Interval.Duration > 0 && schedule.Jitter.Duration >= schedule.Interval.Duration {
+			v.addf(path+".jitter", "must be shorter than the interval %v, got %v", schedule.Interval, schedule.Jitter)
+		}
+	}
+
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (config *Config) validateLogging(v *validator) {
+	lc := config.GetLoggingConfig()
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(lc.Level)); err != nil {
+		v.addf("logging.level", "must be debug, info, warn or error, got %q", lc.Level)
+	}
+	if lc.Format != LogFormatText && lc.Format != LogFormatJSON {
+		v.addf("logging.format", "must be %s or %s, got %q", LogFormatText, LogFormatJSON, lc.Format)
+	}
+	if lc.MaxSize < 0 {
+		v.addf("logging.max-size", "must not be negative, got %v", lc.MaxSize)
+	}
+	v.optionalDuration("logging.rotate-every", lc.RotateEvery)
+	v.nonNegative("logging.max-backups", lc.MaxBackups)
+	v.optionalDuration("logging.max-age", lc.MaxAge)
+}
+
+// minAPISecretLength keeps tokens and HMAC secrets from being guessable.
+const minAPISecretLength = 16
+
+func (config *Config) validateHTTPAPI(v *validator) {
+	tc := config.HTTPAPI.TLS
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		if tc.CertFile == "" {
+			v.addf("http-api.tls.cert-file", "must be set with key-file")
+		} else {
+			v.fileExists("http-api.tls.cert-file", tc.CertFile)
+		}
+		if tc.KeyFile == "" {
+			v.addf("http-api.tls.key-file", "must be set with cert-file")
+		} else {
+			v.fileExists("http-api.tls.key-file", tc.KeyFile)
+		}
+	}
+	if tc.ClientCAFile != "" {
+		if !tc.Enabled() {
+			v.addf("http-api.tls.client-ca-file", "needs cert-file and key-file")
+		}
+		v.fileExists("http-api.tls.client-ca-file", tc.ClientCAFile)
+	}
+
+	auth := config.HTTPAPI.Auth
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	for i, token := range auth.Tokens {
+		path := fmt.Sprintf("http-api.auth.tokens[%d]", i)
+		v.required(path+".name", token.Name)
+		if names[token.Name] {
+			v.addf(path+".name", "duplicate name %q", token.Name)
+		}
+		names[token.Name] = true
+		if len(token.Token) < minAPISecretLength {
+			v.addf(path+".token", "must be at least %d characters", minAPISecretLength)
+		} else if tokens[token.Token] {
+			v.addf(path+".token", "is the same as another token")
+		}
+		tokens[token.Token] = true
+		v.apiScope(path+".scope", token.Scope)
+	}
+	ids := make(map[string]bool)
+	for i, key := range auth.HMACKeys {
+		path := fmt.Sprintf("http-api.auth.hmac-keys[%d]", i)
+		v.required(path+".id", key.ID)
+		if ids[key.ID] {
+			v.addf(path+".id", "duplicate id %q", key.ID)
+		}
+		ids[key.ID] = true
+		if len(key.Secret) < minAPISecretLength {
+			v.addf(path+".secret", "must be at least %d characters", minAPISecretLength)
+		}
+		v.apiScope(path+".scope", key.Scope)
+	}
+	v.optionalDuration("http-api.auth.max-clock-skew", auth.MaxClockSkew)
+}
+
+func (v *validator) apiScope(path, scope string) {
+	if scope != APIScopeRead && scope != APIScopeAdmin {
+		v.addf(path, "must be %s or %s, got %q", APIScopeRead, APIScopeAdmin, scope)
+	}
+}
+
+func (config *Config) validateApiServerConfig(v *validator) {
+	asc := config.ApiServerConfig
+	if asc == nil {
+		v.addf("api-server-config", "is missing")
+		return
+	}
+	v.required("api-server-config.node-id", asc.NodeId)
+	v.listenAddress("api-server-config.api-port", asc.APIPort)
+	v.host("api-server-config.api-server-dns", asc.APIServerDNS)
+	v.required("api-server-config.tenant-list-api", asc.TenantListApi)
+}
+
+func (config *Config) validateControllerConfig(v *validator) {
+	cc := config.ControllerConfig
+	if cc == nil {
+		v.addf("controller-config", "is missing")
+		return
+	}
+	v.required("controller-config.access-keys-dir", cc.AccessKeyDir)
+	v.host("controller-config.controller-dns", cc.ControllerDNS)
+	v.required("controller-config.add-service-account-api", cc.AddServiceAccountApi)
+	v.required("controller-config.get-tenant-info-api", cc.GetTenantInfoApi)
+	v.optionalDuration("controller-config.access-key-refresh-window", cc.AccessKeyRefreshWindow)
+
+	sac := cc.GetServiceAccountConfig()
+	v.nonNegative("controller-config.service-account.permissions", cc.ServiceAccount.Permissions)
+	v.optionalDuration("controller-config.service-account.lifetime", cc.ServiceAccount.Lifetime)
+	if cc.AccessKeyRefreshWindow.Duration >= sac.Lifetime.Duration {
+		v.addf("controller-config.access-key-refresh-window", "must be shorter than the service account lifetime %v, got %v", sac.Lifetime, cc.AccessKeyRefreshWindow)
+	}
+	policy, err := sac.RenderPolicy()
+	if err != nil {
+		v.addf("controller-config.service-account.policy-template", "%v", err)
+	} else if !json.Valid([]byte(policy)) {
+		v.addf("controller-config.service-account.policy-template", "does not render to valid JSON")
+	}
+}
+
+func (config *Config) validateThresholds(v *validator) {
+	slt := config.GetSystemLevelThreshold()
+	if slt.HighAvgLoadThreshold <= 0 {
+		v.addf("system-level-threshold.high-avg-load-threshold", "must be above 0, got %v", slt.HighAvgLoadThreshold)
+	}
+	v.positiveDuration("system-level-threshold.high-avg-load-duration", slt.HighAvgLoadDuration)
+	v.percent("system-level-threshold.high-cpu-usage-threshold", slt.HighCPUusageThreshold)
+	v.positiveDuration("system-level-threshold.high-cpu-usage-duration", slt.HighCPUusageDuration)
+	v.percent("system-level-threshold.high-memory-usage-threshold", slt.HighMemoryUsageThreshold)
+	v.positiveDuration("system-level-threshold.high-memory-usage-duration", slt.HighMemoryUsageDuration)
+	v.percent("system-level-threshold.high-disk-usage-threshold", slt.HighDiskUsageThreshold)
+	v.positiveDuration("system-level-threshold.high-disk-usage-duration", slt.HighDiskUsageDuration)
+
+	v.optionalDuration("tenant-drift-grace-period", config.TenantDriftGracePeriod)
+	v.optionalDuration("tenant-inventory-refresh-interval", config.TenantInventoryRefreshInterval)
+
+	trt := config.TenantRestartThreshold
+	v.optionalDuration("tenant-restart-threshold.restart-deadline", trt.RestartDeadline)
+	v.optionalDuration("tenant-restart-threshold.restart-in-process-deadline", trt.RestartInProcessDeadline)
+	v.nonNegative("tenant-restart-threshold.flap-count", trt.FlapCount)
+	if trt.FlapCount > 0 && trt.FlapWindow.Duration <= 0 {
+		v.addf("tenant-restart-threshold.flap-window", "must be a positive duration when flap-count is set, got %v", trt.FlapWindow)
+	}
+}
@@ -3,8 +3,9 @@ package cryption
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"encoding/base64"
+	"crypto/rand"
 	"fmt"
+	"time"
 )
 
 // Structure to parse JSON input
@@ -15,39 +16,50 @@ type SecretData struct {
 	StatusCode int     `json:"StatusCode"`
 }
 
+// expirationLayouts are the formats the controller uses for Expiration,
+// .NET omits the zone for DateTime values of unspecified kind
+var expirationLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.9999999", "2006-01-02T15:04:05"}
+
+// ExpiresAt parses Expiration. A zero time means the key never expires.
+func (sd *SecretData) ExpiresAt() (time.Time, error) {
+	if sd.Expiration == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range expirationLayouts {
+		expiration, err := time.Parse(layout, sd.Expiration)
+		if err == nil {
+			if expiration.Year() <= 1 {
+				return time.Time{}, nil
+			}
+			return expiration, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid expiration %q", sd.Expiration)
+}
+
 // SString equivalent in Go
 type SString struct {
 	CString string `json:"CString"`
 	DString string `json:"DString"`
 }
 
-// AES decryption function
+// GetDString decrypts CString with the default keyring
 func (s SString) GetDString() (string, error) {
-	// Decode base64 CString
-	dummaBytes := []byte{
-		'0', '0', '0', '0', '0', '0', '0', '0',
-		'0', '0', '0', '0', '0', '0', '0', '0',
-	}
-	encryptedData, err := base64.StdEncoding.DecodeString(s.CString)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 string: %v", err)
-	}
-
-	// Use the provided DummyChars as key (converted to bytes)
-	key := []byte("E8AA3FBB0F512B32") // Must be exactly 16 bytes
-	//iv := make([]byte, aes.BlockSize) // IV is 16 bytes of zero (same as .NET)
-
-	// Decrypt AES-128-CBC
-	plaintext, err := decryptAES128CBC(encryptedData, key, dummaBytes)
-	if err != nil {
-		return "", fmt.Errorf("decryption failed: %v", err)
+	keyring := GetDefaultKeyring()
+	if keyring == nil {
+		return "", fmt.Errorf("no keyring configured")
 	}
+	return keyring.Decrypt(s.CString)
+}
 
-	return string(plaintext), nil
+// cbcIV is the IV the controller uses for AES-CBC, sixteen ASCII '0' characters
+var cbcIV = []byte{
+	'0', '0', '0', '0', '0', '0', '0', '0',
+	'0', '0', '0', '0', '0', '0', '0', '0',
 }
 
-// AES-128-CBC decryption with PKCS7 padding removal
-func decryptAES128CBC(encryptedData, key, iv []byte) ([]byte, error) {
+// AES-CBC decryption with PKCS7 padding removal
+func decryptAESCBC(encryptedData, key, iv []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -56,26 +68,96 @@ func decryptAES128CBC(encryptedData, key, iv []byte) ([]byte, error) {
 	if len(encryptedData) < aes.BlockSize {
 		return nil, fmt.Errorf("ciphertext too short")
 	}
+	if len(encryptedData)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("ciphertext is not a multiple of the block size")
+	}
 
 	mode := cipher.NewCBCDecrypter(block, iv)
 	decrypted := make([]byte, len(encryptedData))
 	mode.CryptBlocks(decrypted, encryptedData)
 
-	// Debug: Print decrypted bytes before removing padding
-	//fmt.Println("Decrypted raw bytes:", decrypted)
-
 	// Remove PKCS7 padding
 	return removePKCS7Padding(decrypted)
 }
 
+// AES-CBC encryption with PKCS7 padding, the inverse of decryptAESCBC
+func encryptAESCBC(plaintext, key, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	padded := addPKCS7Padding(plaintext, aes.BlockSize)
+	encrypted := make([]byte, len(padded))
+	mode := cipher.NewCBCEncrypter(block, iv)
+	mode.CryptBlocks(encrypted, padded)
+	return encrypted, nil
+}
+
+// PKCS7 padding
+func addPKCS7Padding(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+	padded := make([]byte, len(data), len(data)+padding)
+	copy(padded, data)
+	for i := 0; i < padding; i++ {
+		padded = append(padded, byte(padding))
+	}
+	return padded
+}
+
 // PKCS7 padding removal
 func removePKCS7Padding(data []byte) ([]byte, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("empty data")
 	}
 	padding := int(data[len(data)-1])
-	if padding < 1 || padding > len(data) {
+	if padding < 1 || padding > len(data) || padding > aes.BlockSize {
 		return nil, fmt.Errorf("invalid padding length")
 	}
+	// checking every padding byte makes a wrong key far less likely to pass
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, fmt.Errorf("invalid padding")
+		}
+	}
 	return data[:len(data)-padding], nil
 }
+
+// Seal encrypts and authenticates plaintext with AES-GCM. additionalData is
+// authenticated but not encrypted, it must be passed again to Open.
+func Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts data sealed by Seal, failing if it was modified.
+func Open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("sealed data too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("integrity check failed: %v", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,143 @@
+package cryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// controllerKey and controllerCString are a key and a CString encrypted the
+// way the controller does it: AES-CBC with the raw key string, an IV of
+// sixteen ASCII '0' and PKCS7 padding. The CString was generated with
+//
+//	openssl enc -aes-128-cbc -K <hex of key> -iv <hex of "0000000000000000"> -base64
+const (
+	controllerKey       = "0123456789abcdef"
+	controllerCString   = "WvtiGHLeoLW+p7XR713JszAFu5ABLVokHgL58j/GCV8eg7cbtdILL5a1yg9gLea8"
+	controllerPlaintext = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+)
+
+func newTestKeyring(t *testing.T, keys ...string) *Keyring {
+	t.Helper()
+	keyring := NewKeyring()
+	for i := 0; i+1 < len(keys); i += 2 {
+		if err := keyring.AddKey(keys[i], []byte(keys[i+1])); err != nil {
+			t.Fatalf("AddKey(%q): %v", keys[i], err)
+		}
+	}
+	return keyring
+}
+
+func TestDecryptControllerCString(t *testing.T) {
+	keyring := newTestKeyring(t, "controller", controllerKey)
+	plaintext, err := keyring.Decrypt(controllerCString)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if plaintext != controllerPlaintext {
+		t.Fatalf("Decrypt = %q, want %q", plaintext, controllerPlaintext)
+	}
+
+	cstring, err := keyring.Encrypt(controllerPlaintext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if cstring != controllerCString {
+		t.Fatalf("Encrypt = %q, want the controller's %q", cstring, controllerCString)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KeySize)
+	plaintext := []byte("watchdog secret")
+	sealed, err := Seal(key, plaintext, []byte("k1"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	opened, err := Open(key, sealed, []byte("k1"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Fatalf("Open = %q, want %q", opened, plaintext)
+	}
+	if _, err := Open(key, sealed, []byte("k2")); err == nil {
+		t.Fatal("Open with other additional data succeeded")
+	}
+}
+
+func TestDecryptAfterRotation(t *testing.T) {
+	for _, authenticated := range []bool{false, true} {
+		old := newTestKeyring(t, "k1", controllerKey)
+		old.Authenticated = authenticated
+		cstring, err := old.Encrypt(controllerPlaintext)
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+
+		rotated := newTestKeyring(t, "k1", controllerKey, "k2", "fedcba9876543210")
+		if err := rotated.SetPrimary("k2"); err != nil {
+			t.Fatalf("SetPrimary: %v", err)
+		}
+		rotated.Authenticated = authenticated
+		plaintext, err := rotated.Decrypt(cstring)
+		if err != nil {
+			t.Fatalf("authenticated=%v: Decrypt with the old key: %v", authenticated, err)
+		}
+		if plaintext != controllerPlaintext {
+			t.Fatalf("authenticated=%v: Decrypt = %q, want %q", authenticated, plaintext, controllerPlaintext)
+		}
+
+		cstring, err = rotated.Encrypt(controllerPlaintext)
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		if plaintext, err = rotated.Decrypt(cstring); err != nil || plaintext != controllerPlaintext {
+			t.Fatalf("authenticated=%v: Decrypt with the new key = %q, %v", authenticated, plaintext, err)
+		}
+		if authenticated && !strings.HasPrefix(cstring, gcmPrefix+"k2:") {
+			t.Fatalf("Encrypt = %q, want it sealed with the new primary key k2", cstring)
+		}
+	}
+}
+
+func TestDecryptInvalid(t *testing.T) {
+	keyring := newTestKeyring(t, "k1", controllerKey)
+	keyring.Authenticated = true
+	sealed, err := keyring.Encrypt(controllerPlaintext)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, gcmPrefix+"k1:"))
+	data[len(data)-1] ^= 1
+	tampered := gcmPrefix + "k1:" + base64.StdEncoding.EncodeToString(data)
+
+	cbc, _ := base64.StdEncoding.DecodeString(controllerCString)
+	cbc[len(cbc)-aes.BlockSize-1] ^= 1 // garbles the last block's padding
+
+	// a block ending in 0, which is never valid PKCS7 padding
+	block, _ := aes.NewCipher([]byte(controllerKey))
+	zeroPadded := make([]byte, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, cbcIV).CryptBlocks(zeroPadded, make([]byte, aes.BlockSize))
+
+	for name, cstring := range map[string]string{
+		"tampered gcm":       tampered,
+		"unknown gcm key":    gcmPrefix + "k9:" + strings.TrimPrefix(sealed, gcmPrefix+"k1:"),
+		"short gcm":          gcmPrefix + "k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"gcm without key":    gcmPrefix + "k1",
+		"tampered cbc":       base64.StdEncoding.EncodeToString(cbc),
+		"bad padding":        base64.StdEncoding.EncodeToString(zeroPadded),
+		"partial block":      base64.StdEncoding.EncodeToString(cbc[:len(cbc)-1]),
+		"short cbc":          base64.StdEncoding.EncodeToString([]byte("short")),
+		"empty":              "",
+		"invalid base64":     "not base64!",
+		"gcm invalid base64": gcmPrefix + "k1:not base64!",
+	} {
+		if plaintext, err := keyring.Decrypt(cstring); err == nil {
+			t.Errorf("%s: Decrypt = %q, want an error", name, plaintext)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cryption
+
+import (
+	"ChintuIdrive/storage-node-watchdog/fileutil"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeySize is the size of the AES-256 keys used by Seal and Open
+const KeySize = 32
+
+// LoadOrCreateKey returns the base64 encoded key from the environment variable
+// envVar if set, otherwise from keyFile. A missing keyFile is created with a
+// random key readable by the owner only.
+func LoadOrCreateKey(keyFile, envVar string) ([]byte, error) {
+	if encoded := os.Getenv(envVar); encoded != "" {
+		return decodeKey(encoded, envVar)
+	}
+	if keyFile == "" {
+		return nil, fmt.Errorf("no key configured, set %s or a key file", envVar)
+	}
+
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		return decodeKey(string(data), keyFile)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, err
+	}
+	err = fileutil.WriteFileAtomic(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func decodeKey(encoded, source string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %v", source, err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid key in %s: got %d bytes, want %d", source, len(key), KeySize)
+	}
+	return key, nil
+}
@@ -0,0 +1,202 @@
+package cryption
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// gcmPrefix marks a CString produced in authenticated mode, it is followed by
+// the key ID and the base64 encoded nonce and ciphertext: gcm:<key id>:<data>
+const gcmPrefix = "gcm:"
+
+// Keyring holds the keys shared with the API server and the controller. The
+// primary key encrypts, every key is tried when decrypting so keys can be
+// rotated without breaking payloads encrypted with the previous one.
+type Keyring struct {
+	keys  map[string][]byte
+	order []string // primary first
+	// Authenticated makes Encrypt use AES-GCM instead of the AES-CBC the
+	// controller expects
+	Authenticated bool
+}
+
+// keyringFile is the JSON layout of a keyring file, keys are the raw key
+// strings as configured on the controller
+type keyringFile struct {
+	Primary       string            `json:"primary"`
+	Authenticated bool              `json:"authenticated"`
+	Keys          map[string]string `json:"keys"`
+}
+
+var (
+	defaultKeyring     *Keyring
+	defaultKeyringLock sync.RWMutex
+)
+
+// SetDefaultKeyring sets the keyring used by SString.GetDString.
+func SetDefaultKeyring(keyring *Keyring) {
+	defaultKeyringLock.Lock()
+	defer defaultKeyringLock.Unlock()
+	defaultKeyring = keyring
+}
+
+func GetDefaultKeyring() *Keyring {
+	defaultKeyringLock.RLock()
+	defer defaultKeyringLock.RUnlock()
+	return defaultKeyring
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys: make(map[string][]byte),
+	}
+}
+
+// AddKey adds a key, the first key added is the primary one.
+func (kr *Keyring) AddKey(id string, key []byte) error {
+	if id == "" {
+		return fmt.Errorf("empty key id")
+	}
+	if strings.Contains(id, ":") {
+		return fmt.Errorf("key id %q must not contain ':'", id)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("key %q is %d bytes, want 16, 24 or 32", id, len(key))
+	}
+	if _, found := kr.keys[id]; found {
+		return fmt.Errorf("duplicate key id %q", id)
+	}
+	kr.keys[id] = key
+	kr.order = append(kr.order, id)
+	return nil
+}
+
+// SetPrimary makes the key with the given id the one used by Encrypt.
+func (kr *Keyring) SetPrimary(id string) error {
+	for i, keyID := range kr.order {
+		if keyID == id {
+			kr.order = append([]string{id}, append(kr.order[:i:i], kr.order[i+1:]...)...)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown key id %q", id)
+}
+
+func (kr *Keyring) Len() int {
+	return len(kr.order)
+}
+
+// LoadKeyring reads the keys from the environment variable envVar if set,
+// formatted as id=key pairs separated by commas with the primary key first,
+// otherwise from the JSON keyring file. An empty keyring is returned when
+// neither is configured.
+func LoadKeyring(keyringPath, envVar string) (*Keyring, error) {
+	keyring := NewKeyring()
+	if value := os.Getenv(envVar); value != "" {
+		for _, pair := range strings.Split(value, ",") {
+			id, key, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if !found {
+				return nil, fmt.Errorf("invalid key in %s, want id=key", envVar)
+			}
+			if err := keyring.AddKey(id, []byte(key)); err != nil {
+				return nil, fmt.Errorf("%s: %v", envVar, err)
+			}
+		}
+		return keyring, nil
+	}
+	if keyringPath == "" {
+		return keyring, nil
+	}
+
+	data, err := os.ReadFile(keyringPath)
+	if err != nil {
+		return nil, err
+	}
+	var kf keyringFile
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("invalid keyring file %s: %v", keyringPath, err)
+	}
+	for id, key := range kf.Keys {
+		if err := keyring.AddKey(id, []byte(key)); err != nil {
+			return nil, fmt.Errorf("keyring file %s: %v", keyringPath, err)
+		}
+	}
+	if kf.Primary == "" && keyring.Len() > 1 {
+		return nil, fmt.Errorf("keyring file %s has several keys but no primary", keyringPath)
+	}
+	if kf.Primary != "" {
+		if err := keyring.SetPrimary(kf.Primary); err != nil {
+			return nil, fmt.Errorf("keyring file %s: %v", keyringPath, err)
+		}
+	}
+	keyring.Authenticated = kf.Authenticated
+	return keyring, nil
+}
+
+// Encrypt encrypts plaintext with the primary key into a CString.
+func (kr *Keyring) Encrypt(plaintext string) (string, error) {
+	if len(kr.order) == 0 {
+		return "", fmt.Errorf("keyring is empty")
+	}
+	keyID := kr.order[0]
+	key := kr.keys[keyID]
+	if kr.Authenticated {
+		sealed, err := Seal(key, []byte(plaintext), []byte(keyID))
+		if err != nil {
+			return "", err
+		}
+		return gcmPrefix + keyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+	}
+	encrypted, err := encryptAESCBC([]byte(plaintext), key, cbcIV)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// Decrypt decrypts a CString. Authenticated payloads name their key, for
+// AES-CBC payloads every key is tried, primary first.
+func (kr *Keyring) Decrypt(cstring string) (string, error) {
+	if len(kr.order) == 0 {
+		return "", fmt.Errorf("keyring is empty")
+	}
+	if strings.HasPrefix(cstring, gcmPrefix) {
+		keyID, encoded, found := strings.Cut(strings.TrimPrefix(cstring, gcmPrefix), ":")
+		if !found {
+			return "", fmt.Errorf("invalid authenticated payload")
+		}
+		key, found := kr.keys[keyID]
+		if !found {
+			return "", fmt.Errorf("unknown key id %q", keyID)
+		}
+		sealed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode base64 string: %v", err)
+		}
+		plaintext, err := Open(key, sealed, []byte(keyID))
+		if err != nil {
+			return "", err
+		}
+		return string(plaintext), nil
+	}
+
+	encryptedData, err := base64.StdEncoding.DecodeString(cstring)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64 string: %v", err)
+	}
+	var lastErr error
+	for _, keyID := range kr.order {
+		plaintext, err := decryptAESCBC(encryptedData, kr.keys[keyID], cbcIV)
+		if err == nil {
+			return string(plaintext), nil
+		}
+		lastErr = err
+	}
+	return "", fmt.Errorf("decryption failed: %v", lastErr)
+}
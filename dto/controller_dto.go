@@ -1,6 +1,9 @@
 package dto
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type ServiceAccountReq struct {
 	BaseReq
@@ -46,15 +49,15 @@ type TenantProcessInfoResponse struct {
 }
 type TenatWithProcessInfo struct {
 	PlannedRestart                bool
-	PublicBucketsEnabled          bool
+	PublicBucketsEnabled          bool `json:"public_buckets_enabled"`
 	UserType                      string
 	E2UserID                      string
 	UserID                        string
 	DNS                           string
 	ProcessID                     int
 	Password                      Password
-	AdminPort                     int
-	S3Port                        int
+	AdminPort                     int `json:"admin_port"`
+	S3Port                        int `json:"s3_port"`
 	FailedS3HealthChecks          int
 	ProcessStartTime              string
 	CNameList                     []string
@@ -78,3 +81,11 @@ type TenatWithProcessInfo struct {
 	UploadLimit                   int
 	DownloadLimit                 int
 }
+
+// LocalS3Endpoint returns the host:port of the tenant's minio process on this node.
+func (resp *TenantProcessInfoResponse) LocalS3Endpoint() string {
+	if resp.LocalMinioAdminEndpoint != "" {
+		return resp.LocalMinioAdminEndpoint
+	}
+	return fmt.Sprintf("localhost:%d", resp.TenatWithProcessInfo.S3Port)
+}
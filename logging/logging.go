@@ -0,0 +1,108 @@
+package logging
+
+import (
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// level is shared by every handler Setup creates, so SetLevel applies at once.
+var level = new(slog.LevelVar)
+
+// Open opens the log file of the config with its rotation settings.
+func Open(path string, lc conf.LoggingConfig) (*RotatingFile, error) {
+	return OpenRotatingFile(path, int64(lc.MaxSize), lc.RotateEvery.Duration, lc.MaxBackups, lc.MaxAge.Duration)
+}
+
+// Setup makes slog write to w in the configured format and level. The lines
+// of the log package go through the same handler at info level. Every line
+// gets the source and the package that logged it as component.
+func Setup(w io.Writer, lc conf.LoggingConfig) error {
+	if err := SetLevel(lc.Level); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       level,
+		ReplaceAttr: shortSource,
+	}
+	var handler slog.Handler
+	if lc.Format == conf.LogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	// with a file flag the log package passes the caller on to slog
+	log.SetFlags(log.Lshortfile)
+	slog.SetDefault(slog.New(&componentHandler{Handler: handler}))
+	return nil
+}
+
+// SetLevel changes the level of the logger at runtime.
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// Level returns the current level, e.g. "info".
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// shortSource writes the source as file:line, like log.Lshortfile.
+func shortSource(groups []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.SourceKey || len(groups) > 0 {
+		return a
+	}
+	source, ok := a.Value.Any().(*slog.Source)
+	if !ok {
+		return a
+	}
+	return slog.String(slog.SourceKey, filepath.Base(source.File)+":"+strconv.Itoa(source.Line))
+}
+
+// componentHandler adds the package of the caller as the component field.
+type componentHandler struct {
+	slog.Handler
+}
+
+func (h *componentHandler) Handle(ctx context.Context, r slog.Record) error {
+	if component := componentOf(r.PC); component != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String("component", component))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *componentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &componentHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *componentHandler) WithGroup(name string) slog.Handler {
+	return &componentHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// componentOf returns the last element of the package path of the function
+// at pc, e.g. "monitor".
+func componentOf(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	function := frame.Function
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		function = function[i+1:]
+	}
+	pkg, _, _ := strings.Cut(function, ".")
+	return pkg
+}
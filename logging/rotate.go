@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat sorts lexically and keeps two rotations within a second apart
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile is an append-only log file that is renamed to
+// <name>-<time><ext> when it grows past maxSize or gets older than
+// rotateEvery. Old rotated files are removed beyond maxBackups or maxAge.
+type RotatingFile struct {
+	path        string
+	maxSize     int64
+	rotateEvery time.Duration
+	maxBackups  int
+	maxAge      time.Duration
+
+	lock     sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func OpenRotatingFile(path string, maxSize int64, rotateEvery time.Duration, maxBackups int, maxAge time.Duration) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:        path,
+		maxSize:     maxSize,
+		rotateEvery: rotateEvery,
+		maxBackups:  maxBackups,
+		maxAge:      maxAge,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// open must be called with the lock held or before the file is shared.
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = info.Size()
+	// a file kept from the previous run counts from its last write
+	rf.openedAt = time.Now()
+	if rf.size > 0 {
+		rf.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+
+	if rf.size > 0 && rf.needsRotation(int64(len(p))) {
+		if err := rf.rotate(); err != nil {
+			// keep logging to the current file rather than losing lines
+			os.Stderr.WriteString("log rotation failed: " + err.Error() + "\n")
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) needsRotation(next int64) bool {
+	if rf.maxSize > 0 && rf.size+next > rf.maxSize {
+		return true
+	}
+	return rf.rotateEvery > 0 && time.Since(rf.openedAt) >= rf.rotateEvery
+}
+
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(rf.path, rf.backupName(time.Now())); err != nil {
+		if reopenErr := rf.open(); reopenErr != nil {
+			return reopenErr
+		}
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	rf.removeOldBackups()
+	return nil
+}
+
+func (rf *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(rf.path)
+	return strings.TrimSuffix(rf.path, ext) + "-" + t.Format(backupTimeFormat) + ext
+}
+
+// backups returns the rotated files, newest first.
+func (rf *RotatingFile) backups() []string {
+	ext := filepath.Ext(rf.path)
+	matches, _ := filepath.Glob(strings.TrimSuffix(rf.path, ext) + "-*" + ext)
+	var backups []string
+	for _, match := range matches {
+		if _, err := rf.backupTime(match); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups
+}
+
+func (rf *RotatingFile) backupTime(backup string) (time.Time, error) {
+	ext := filepath.Ext(rf.path)
+	stamp := strings.TrimSuffix(strings.TrimPrefix(backup, strings.TrimSuffix(rf.path, ext)+"-"), ext)
+	return time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+}
+
+func (rf *RotatingFile) removeOldBackups() {
+	for i, backup := range rf.backups() {
+		expired := false
+		if rf.maxAge > 0 {
+			if rotatedAt, err := rf.backupTime(backup); err == nil && time.Since(rotatedAt) > rf.maxAge {
+				expired = true
+			}
+		}
+		if (rf.maxBackups > 0 && i >= rf.maxBackups) || expired {
+			os.Remove(backup)
+		}
+	}
+}
+
+// Sync flushes the file to disk.
+func (rf *RotatingFile) Sync() error {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	return rf.file.Sync()
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	return rf.file.Close()
+}
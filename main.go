@@ -1,71 +1,355 @@
 package main
 
 import (
+	"ChintuIdrive/storage-node-watchdog/alert"
 	"ChintuIdrive/storage-node-watchdog/api"
 	"ChintuIdrive/storage-node-watchdog/clients"
 	"ChintuIdrive/storage-node-watchdog/collector"
 	"ChintuIdrive/storage-node-watchdog/conf"
+	"ChintuIdrive/storage-node-watchdog/cryption"
+	"ChintuIdrive/storage-node-watchdog/logging"
 	"ChintuIdrive/storage-node-watchdog/monitor"
+	"context"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
-// Log file setup
-var (
-	logFile *os.File
-	config  *conf.Config
-)
+const usage = `Usage: storage-node-watchdog [command] [flags]
 
-func init() {
-	var err error
-	if _, err := os.Stat("conf/config.json"); os.IsNotExist(err) {
-		config = conf.GetDefaultConfig()
-		configFile, err := os.Create("conf/config.json")
-		if err != nil {
-			log.Fatalf("Failed to create config file: %s", err)
+Commands:
+  run            monitor the node and serve the HTTP API, the default
+  check-config   validate the config without changing it, non-zero if it is invalid or needs migration
+  once           collect every metric once and print them as JSON
+  tenants        print the tenant inventory as JSON
+  probe          run the S3 probes of the tenant given by --tenant and print the result as JSON
+  alerts         print the active alerts of the running watchdog as JSON
+
+Settings are applied in this order, a later one wins:
+  1. the config file, created with the built-in defaults if it is missing
+     and migrated to the current schema version by run, only read by the
+     other commands
+  2. WATCHDOG_* environment variables, named after the JSON path of the
+     field, e.g. WATCHDOG_API_SERVER_CONFIG_API_SERVER_KEY
+  3. command-line flags
+Settings left out of the config file keep their built-in default where
+they have one, the others must be set.
+
+Run "storage-node-watchdog <command> -h" for the flags of a command.
+`
+
+type command struct {
+	name string
+	run  func(args []string) error
+}
+
+var commands = []command{
+	{"run", runCommand},
+	{"check-config", checkConfigCommand},
+	{"once", onceCommand},
+	{"tenants", tenantsCommand},
+	{"probe", probeCommand},
+	{"alerts", alertsCommand},
+}
+
+func main() {
+	args := os.Args[1:]
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		fmt.Print(usage)
+		return
+	}
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args); err != nil {
+			log.Fatalf("%s: %s", name, err)
 		}
-		defer configFile.Close()
+		return
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", name, usage)
+	os.Exit(2)
+}
 
-		configData, err := json.MarshalIndent(config, "", "  ")
-		if err != nil {
-			log.Fatalf("Failed to marshal default config: %s", err)
+// options are the flags every command accepts.
+type options struct {
+	configPath  string
+	logFilePath string
+	listen      string
+	dataDir     string
+}
+
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&opts.configPath, "config", getEnv("WATCHDOG_CONFIG", "conf/config.json"), "config file, run creates it with defaults if missing (env WATCHDOG_CONFIG)")
+	fs.StringVar(&opts.logFilePath, "log-file", "", "log file, overrides log-file-path")
+	fs.StringVar(&opts.listen, "listen", "", "[host]:port the HTTP API listens on, overrides api-server-config.api-port")
+	fs.StringVar(&opts.dataDir, "data-dir", os.Getenv("WATCHDOG_DATA_DIR"), "directory the relative paths in the config are resolved against (env WATCHDOG_DATA_DIR)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: storage-node-watchdog %s [flags]\n\nFlags:\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// overrides applies the environment and the flags on top of the config file.
+func (opts *options) overrides(config *conf.Config) error {
+	if err := config.ApplyEnv(os.Environ()); err != nil {
+		return err
+	}
+	if opts.dataDir != "" {
+		config.ResolvePaths(opts.dataDir)
+	}
+	if opts.logFilePath != "" {
+		config.LogFilePath = opts.logFilePath
+	}
+	if opts.listen != "" {
+		if config.ApiServerConfig == nil {
+			config.ApiServerConfig = &conf.ApiServerConfig{}
 		}
-		configFile.Write(configData)
-
-		// encoder := json.NewEncoder(configFile)
-		// if err := encoder.Encode(defaultConfig); err != nil {
-		// 	log.Fatalf("Failed to write default config to file: %s", err)
-		// }
-	} else {
-		config, err = conf.LoadConfig("conf/config.json")
-		if err != nil {
-			log.Fatalf("Failed to load config: %s", err)
+		config.ApiServerConfig.APIPort = opts.listen
+	}
+	return nil
+}
+
+// loadConfigStore loads the config, writing the defaults first if the file is
+// missing. The config is not validated.
+func (opts *options) loadConfigStore() (*conf.ConfigStore, error) {
+	if _, err := os.Stat(opts.configPath); os.IsNotExist(err) {
+		if err := writeDefaultConfig(opts.configPath); err != nil {
+			return nil, fmt.Errorf("failed to create config file: %w", err)
 		}
 	}
+	configStore, err := conf.NewConfigStore(opts.configPath, opts.overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return configStore, nil
+}
 
-	logFile, err = os.OpenFile("watchdog.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// readConfigStore loads the config for the one-shot commands, which never
+// write to the file: a missing file is an error and a file in an older schema
+// version is migrated in memory only. The config is not validated.
+func (opts *options) readConfigStore() (*conf.ConfigStore, error) {
+	configStore, err := conf.NewReadOnlyConfigStore(opts.configPath, opts.overrides)
 	if err != nil {
-		log.Fatalf("Failed to open log file: %s", err)
+		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
-	log.SetOutput(logFile)
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	return configStore, nil
 }
 
-func main() {
-	asc := clients.NewApiServerClient(config.ApiServerConfig)
-	// tenantsFromApiServer, err := asc.GetTenatsListFromApiServer()
-	// if err != nil {
-	// 	//notify watchdog not able to fetch tenantlist from api server
-	// 	log.Printf("Failed to fetch tenant list from API server: %v", err)
-	// }
-	// config.LoadS3Config(tenantsFromApiServer)
+// readValidConfigStore is readConfigStore for the commands that use the config.
+func (opts *options) readValidConfigStore() (*conf.ConfigStore, error) {
+	configStore, err := opts.readConfigStore()
+	if err != nil {
+		return nil, err
+	}
+	if err := configStore.Get().Validate(); err != nil {
+		return nil, err
+	}
+	return configStore, nil
+}
+
+func getEnv(name, fallback string) string {
+	if value, found := os.LookupEnv(name); found {
+		return value
+	}
+	return fallback
+}
+
+func writeDefaultConfig(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	configData, err := json.MarshalIndent(conf.GetDefaultConfig(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, configData, 0644)
+}
+
+// watchdog holds the clients and collectors shared by the commands.
+type watchdog struct {
+	configStore *conf.ConfigStore
+	cc          *clients.ControllerClient
+	asc         *clients.APIserverClient
+	tinv        *collector.TenantInventory
+	ssc         *collector.SystemStatsCollector
+	pmc         *collector.ProcesMetricsCollector
+	s3mc        *collector.S3MetricCollector
+	tuc         *collector.TenantUsageCollector
+	mhc         *collector.MinioHealthCollector
+	hsc         *collector.HealStatusCollector
+	tdc         *collector.TenantDriftCollector
+	trc         *collector.TenantRestartCollector
+	trsc        *collector.TenantRequestStatsCollector
+}
+
+// newWatchdog loads the keyring, builds the clients and collectors and
+// fetches the tenant inventory.
+func newWatchdog(ctx context.Context, configStore *conf.ConfigStore) (*watchdog, error) {
+	config := configStore.Get()
+	keyring, err := config.LoadKeyring()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load keyring: %w", err)
+	}
+	cryption.SetDefaultKeyring(keyring)
+
+	clients.ConfigureHTTPClient(config.GetHTTPClientConfig())
+	clients.ConfigureDependencies(config.GetCircuitBreakerConfig())
 	cc := clients.NewControllerClientt(config.ControllerConfig)
-	//cc.LadAccessKeys(tenantsFromApiServer)
-	ssc := collector.NewSystemStatsCollector(config)
-	pmc := collector.NewProcesMetricsCollector(config)
-	s3mc := collector.NewS3MetricCollector(config, cc)
+	asc := clients.NewApiServerClient(config.ApiServerConfig, cc)
+	cm := clients.NewCredentialManager(cc)
+	tinv := collector.NewTenantInventory(asc, cc)
+	wd := &watchdog{
+		configStore: configStore,
+		cc:          cc,
+		asc:         asc,
+		tinv:        tinv,
+		ssc:         collector.NewSystemStatsCollector(configStore),
+		pmc:         collector.NewProcesMetricsCollector(configStore),
+		s3mc:        collector.NewS3MetricCollector(configStore, cc, cm),
+		tuc:         collector.NewTenantUsageCollector(cc),
+		mhc:         collector.NewMinioHealthCollector(cc),
+		hsc:         collector.NewHealStatusCollector(cc),
+		tdc:         collector.NewTenantDriftCollector(cc),
+		trc:         collector.NewTenantRestartCollector(cc),
+		trsc:        collector.NewTenantRequestStatsCollector(tinv),
+	}
+	if err := wd.tinv.Refresh(ctx); err != nil {
+		slog.Warn("Failed to load tenant inventory, monitoring no tenants until the next refresh", "error", err)
+	}
+	return wd, nil
+}
+
+// configReloadPollInterval is how often the config file is checked for changes
+const configReloadPollInterval = 30 * time.Second
+
+func runCommand(args []string) error {
+	var opts options
+	fs := newFlagSet("run", &opts)
+	checkOnly := fs.Bool("check-config", false, "validate the config and exit, same as the check-config command")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "%s\nFlags of run:\n", usage)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *checkOnly {
+		return checkConfig(&opts)
+	}
+
+	configStore, err := opts.loadConfigStore()
+	if err != nil {
+		return err
+	}
+	config := configStore.Get()
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("failed to start: %w", err)
+	}
+
+	lc := config.GetLoggingConfig()
+	logFile, err := logging.Open(config.LogFilePath, lc)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	if err := logging.Setup(logFile, lc); err != nil {
+		return fmt.Errorf("failed to set up logging: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	wd, err := newWatchdog(ctx, configStore)
+	if err != nil {
+		return err
+	}
+	configStore.Subscribe(func(old, new *conf.Config) {
+		applyConfig(old, new, wd.asc, wd.cc)
+	})
+
+	go configStore.Watch(ctx, configReloadPollInterval)
+
+	scheduler := monitor.StartMonitoring(ctx, configStore, wd.cc, wd.asc, wd.tinv, wd.ssc, wd.pmc, wd.s3mc, wd.tuc, wd.mhc, wd.hsc, wd.tdc, wd.trc, wd.trsc)
+	api.RegisterHandlers(configStore, scheduler, wd.cc, wd.asc, wd.tinv, wd.ssc, wd.pmc, wd.s3mc, wd.tuc, wd.mhc, wd.hsc, wd.tdc, wd.trc, wd.trsc)
+	if !config.HTTPAPI.Auth.Enabled() {
+		slog.Warn("http-api.auth has no tokens or hmac-keys, the admin endpoints of the HTTP API are disabled", "listen", config.ApiServerConfig.APIPort)
+		if config.HTTPAPI.Auth.AllowAnonymousRead {
+			slog.Warn("http-api.auth.allow-anonymous-read is set, the read endpoints of the HTTP API are open to anyone who can reach it", "listen", config.ApiServerConfig.APIPort)
+		} else if !config.HTTPAPI.TLS.Enabled() || config.HTTPAPI.TLS.ClientCAFile == "" {
+			slog.Warn("The read endpoints of the HTTP API are disabled too, set http-api.auth or allow-anonymous-read", "listen", config.ApiServerConfig.APIPort)
+		}
+	} else if !config.HTTPAPI.TLS.Enabled() && len(config.HTTPAPI.Auth.Tokens) > 0 {
+		slog.Warn("http-api.tls is not set, bearer tokens of the HTTP API are sent in clear text")
+	}
+	serveErr := api.Serve(ctx, config.ApiServerConfig.APIPort, config.HTTPAPI.TLS, config.GetShutdownTimeout())
+	if serveErr != nil {
+		slog.Error("HTTP API failed", "error", serveErr)
+	}
+	// a second signal kills the process
+	stop()
+	shutdown(configStore.Get(), scheduler, wd)
+	logFile.Close()
+	return serveErr
+}
+
+// shutdown waits for the monitors to finish their cycle and writes the
+// state that is kept in memory to disk.
+func shutdown(config *conf.Config, scheduler *monitor.Scheduler, wd *watchdog) {
+	slog.Info("Shutting down, waiting for the monitors", "timeout", config.GetShutdownTimeout())
+	if !scheduler.Wait(config.GetShutdownTimeout()) {
+		slog.Warn("Monitors did not stop in time, exiting anyway", "timeout", config.GetShutdownTimeout())
+	}
+	if err := wd.asc.FlushTenantListCache(); err != nil {
+		slog.Error("Failed to save the tenant list cache", "error", err)
+	}
+	slog.Info("Stopped")
+}
+
+// applyConfig hands a reloaded configuration to the components that keep
+// their own copy of it. Collectors and monitors read the config store.
+func applyConfig(old, new *conf.Config, asc *clients.APIserverClient, cc *clients.ControllerClient) {
+	clients.ConfigureHTTPClient(new.GetHTTPClientConfig())
+	clients.ConfigureDependencies(new.GetCircuitBreakerConfig())
+	asc.SetConfig(new.ApiServerConfig)
+	cc.SetConfig(new.ControllerConfig)
 
-	monitor.StartMonitoring(config, cc, asc, ssc, pmc, s3mc)
-	api.RegisterHandlers(config, cc, asc, ssc, pmc, s3mc)
+	if old.KeyringFile != new.KeyringFile || old.ApiServerConfig.APIServerKey != new.ApiServerConfig.APIServerKey {
+		keyring, err := new.LoadKeyring()
+		if err != nil {
+			alert.RaiseUntilResolved("keyring-reload", "Failed to load the reloaded keyring, keeping the previous one: %v", err)
+		} else {
+			cryption.SetDefaultKeyring(keyring)
+			alert.Resolve("keyring-reload")
+		}
+	}
+	if old.ControllerConfig.GetCredentialKeyFile() != new.ControllerConfig.GetCredentialKeyFile() {
+		slog.Warn("controller-config.credential-key-file changed, it takes effect after a restart")
+	}
+	if old.ApiServerConfig.APIPort != new.ApiServerConfig.APIPort {
+		slog.Warn("api-server-config.api-port changed, it takes effect after a restart")
+	}
+	if old.HTTPAPI.TLS != new.HTTPAPI.TLS {
+		slog.Warn("http-api.tls changed, it takes effect after a restart")
+	}
+	oldLogging, newLogging := old.GetLoggingConfig(), new.GetLoggingConfig()
+	if oldLogging.Level != newLogging.Level {
+		if err := logging.SetLevel(newLogging.Level); err != nil {
+			slog.Error("Failed to apply logging.level", "error", err)
+		}
+	}
+	oldLogging.Level, newLogging.Level = "", ""
+	if oldLogging != newLogging || old.LogFilePath != new.LogFilePath {
+		slog.Warn("logging settings other than level changed, they take effect after a restart")
+	}
 }
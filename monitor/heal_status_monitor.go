@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"ChintuIdrive/storage-node-watchdog/dto"
+	"context"
+	"log/slog"
+)
+
+type HealStatusMonitor struct {
+	tenantInventory      *collector.TenantInventory
+	healStatusCollector  *collector.HealStatusCollector
+	systemStatsCollector *collector.SystemStatsCollector
+}
+
+func NewHealStatusMonitor(hsc *collector.HealStatusCollector, ssc *collector.SystemStatsCollector, tinv *collector.TenantInventory) *HealStatusMonitor {
+	return &HealStatusMonitor{
+		tenantInventory:      tinv,
+		healStatusCollector:  hsc,
+		systemStatsCollector: ssc,
+	}
+}
+
+func (hsm *HealStatusMonitor) MonitorTenantsHealStatus(ctx context.Context) {
+	nodeInfo := hsm.tenantInventory.GetNodeInfo()
+	var healingTenants []string
+	healWorkers := make(map[string]int)
+	for _, tenant := range nodeInfo.TenantList {
+		if ctx.Err() != nil {
+			return
+		}
+		healStatus, err := hsm.healStatusCollector.CollectHealStatus(ctx, tenant)
+		if err != nil {
+			slog.Warn("Failed to collect heal status", "tenant", tenant.DNS, "error", err)
+			continue
+		}
+		if !healStatus.Healing {
+			continue
+		}
+		healingTenants = append(healingTenants, tenant.DNS)
+		healWorkers[tenant.DNS] = healStatus.ActiveHealWorkers
+		slog.Info("Tenant is healing", "tenant", healStatus.DNS, "healing_drives", len(healStatus.HealingDrives),
+			"heal_workers", healStatus.ActiveHealWorkers, "items_healed", healStatus.ItemsHealed, "items_failed", healStatus.ItemsFailed, "healing_for", healStatus.Duration)
+	}
+	hsm.checkHealingLimits(nodeInfo, healingTenants, healWorkers)
+}
+
+// checkHealingLimits verifies the node stays within the healing limits the API
+// server specifies. A limit of zero means the API server did not set one.
+func (hsm *HealStatusMonitor) checkHealingLimits(nodeInfo *dto.TenantList, healingTenants []string, healWorkers map[string]int) {
+	concurrentTenantsLimit, avgLoadLimit := nodeInfo.HealingConcurrentTenants, nodeInfo.HealingAvgLoadLimit
+	for _, dns := range healingTenants {
+		if nodeInfo.HealingThreadPerTenant > 0 && healWorkers[dns] > nodeInfo.HealingThreadPerTenant {
+			alert.Raise("tenant-heal-workers/"+dns, "Tenant %s heals with %d workers, limit is %d", dns, healWorkers[dns], nodeInfo.HealingThreadPerTenant)
+		}
+	}
+	if concurrentTenantsLimit > 0 && len(healingTenants) > concurrentTenantsLimit {
+		alert.Raise("healing-concurrency", "%d tenants are healing concurrently, limit is %d: %v", len(healingTenants), concurrentTenantsLimit, healingTenants)
+	}
+	if avgLoadLimit > 0 && len(healingTenants) > 0 {
+		systemStats := hsm.systemStatsCollector.CollectSystemMetrics()
+		if systemStats.CPUStats.AvgLoad1 > float64(avgLoadLimit) {
+			alert.Raise("healing-load", "Avg Load1 %.2f is above the healing load limit %d while %d tenants are healing",
+				systemStats.CPUStats.AvgLoad1, avgLoadLimit, len(healingTenants))
+		}
+	}
+}
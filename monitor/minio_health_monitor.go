@@ -0,0 +1,66 @@
+package monitor
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+type MinioHealthMonitor struct {
+	configStore          *conf.ConfigStore
+	tenantInventory      *collector.TenantInventory
+	minioHealthCollector *collector.MinioHealthCollector
+}
+
+func NewMinioHealthMonitor(configStore *conf.ConfigStore, mhc *collector.MinioHealthCollector, tinv *collector.TenantInventory) *MinioHealthMonitor {
+	return &MinioHealthMonitor{
+		configStore:          configStore,
+		tenantInventory:      tinv,
+		minioHealthCollector: mhc,
+	}
+}
+
+func (mhm *MinioHealthMonitor) MonitorTenantsMinioHealth(ctx context.Context) {
+	tenants := mhm.tenantInventory.GetTenants()
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		minioHealth, err := mhm.minioHealthCollector.CollectMinioHealth(ctx, tenant)
+		if err != nil {
+			slog.Warn("Failed to collect minio health", "tenant", tenant.DNS, "error", err)
+			continue
+		}
+		mhm.checkMinioHealth(minioHealth)
+	}
+}
+
+func (mhm *MinioHealthMonitor) checkMinioHealth(minioHealth *collector.MinioHealth) {
+	slog.Info("Minio health", "tenant", minioHealth.DNS, "online_drives", minioHealth.OnlineDrives, "offline_drives", minioHealth.OfflineDrives,
+		"faulty_drives", minioHealth.FaultyDrives, "healing_drives", minioHealth.HealingDrives)
+
+	if minioHealth.OfflineDrives > 0 || minioHealth.FaultyDrives > 0 {
+		alert.Raise("tenant-drives/"+minioHealth.DNS, "Tenant %s has %d offline and %d faulty drives", minioHealth.DNS, minioHealth.OfflineDrives, minioHealth.FaultyDrives)
+	} else {
+		alert.Resolve("tenant-drives/" + minioHealth.DNS)
+	}
+	for _, set := range minioHealth.ErasureSets {
+		if !set.Healthy {
+			alert.Raise(fmt.Sprintf("tenant-erasure-set/%s/%d/%d", minioHealth.DNS, set.PoolIndex, set.SetIndex), "Tenant %s erasure set %d in pool %d has %d drives offline, parity is %d",
+				minioHealth.DNS, set.SetIndex, set.PoolIndex, set.OfflineDrives, set.Parity)
+		}
+	}
+
+	fleetVersion := mhm.configStore.Get().FleetMinioVersion
+	for _, server := range minioHealth.Servers {
+		if server.State != "online" {
+			alert.Raise("tenant-server-state/"+minioHealth.DNS+"/"+server.Endpoint, "Tenant %s server %s is %s", minioHealth.DNS, server.Endpoint, server.State)
+		}
+		if fleetVersion != "" && server.Version != fleetVersion {
+			alert.Raise("tenant-server-version/"+minioHealth.DNS+"/"+server.Endpoint, "Tenant %s server %s runs minio %s, fleet baseline is %s", minioHealth.DNS, server.Endpoint, server.Version, fleetVersion)
+		}
+	}
+}
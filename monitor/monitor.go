@@ -4,18 +4,49 @@ import (
 	"ChintuIdrive/storage-node-watchdog/clients"
 	"ChintuIdrive/storage-node-watchdog/collector"
 	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"time"
 )
 
-func StartMonitoring(config *conf.Config, cc *clients.ControllerClient, asc *clients.APIserverClient,
-	ssc *collector.SystemStatsCollector, pmc *collector.ProcesMetricsCollector, s3mc *collector.S3MetricCollector) {
+// StartMonitoring starts the monitors, they run until ctx is done.
+func StartMonitoring(ctx context.Context, configStore *conf.ConfigStore, cc *clients.ControllerClient, asc *clients.APIserverClient, tinv *collector.TenantInventory,
+	ssc *collector.SystemStatsCollector, pmc *collector.ProcesMetricsCollector, s3mc *collector.S3MetricCollector,
+	tuc *collector.TenantUsageCollector, mhc *collector.MinioHealthCollector,
+	hsc *collector.HealStatusCollector, tdc *collector.TenantDriftCollector,
+	trc *collector.TenantRestartCollector, trsc *collector.TenantRequestStatsCollector) *Scheduler {
+	scheduler := NewScheduler(configStore, NewSupervisor(ctx))
+
+	tenantInventoryMonitor := NewTenantInventoryMonitor(tinv)
+	scheduler.Schedule("tenant-inventory", tenantInventorySchedule, tenantInventoryMonitor.MonitorTenantInventory)
 
 	systemStatsMonitor := NewSystemStatsMonitor(ssc)
-	go systemStatsMonitor.MonitorSystemStats()
+	scheduler.Schedule("system-stats", every(15*time.Second), systemStatsMonitor.MonitorSystemStats)
+
+	processStatsMonitor := NewPrcessStatsMonitor(pmc, s3mc, trsc, tinv, cc)
+	scheduler.Schedule("processes", every(15*time.Minute), processStatsMonitor.MonitorProcess)
+	scheduler.Schedule("tenant-process-metrics", every(15*time.Minute), processStatsMonitor.MonitorTenantsProcessMetrics)
+	scheduler.Schedule("tenant-s3-stats", every(15*time.Minute), processStatsMonitor.MonitorTenantsS3Stats)
+
+	tenantUsageMonitor := NewTenantUsageMonitor(tuc, tinv)
+	scheduler.Schedule("tenant-usage", every(15*time.Minute), tenantUsageMonitor.MonitorTenantsUsage)
+
+	minioHealthMonitor := NewMinioHealthMonitor(configStore, mhc, tinv)
+	scheduler.Schedule("tenant-minio-health", every(15*time.Minute), minioHealthMonitor.MonitorTenantsMinioHealth)
+
+	healStatusMonitor := NewHealStatusMonitor(hsc, ssc, tinv)
+	scheduler.Schedule("tenant-heal-status", every(15*time.Minute), healStatusMonitor.MonitorTenantsHealStatus)
 
-	processStatsMonitor := NewPrcessStatsMonitor(pmc, s3mc, asc, cc)
+	tenantDriftMonitor := NewTenantDriftMonitor(configStore, asc, tdc, tinv)
+	tinv.Subscribe(tenantDriftMonitor.handleTenantEvent)
+	scheduler.Schedule("tenant-drift", every(15*time.Minute), tenantDriftMonitor.MonitorTenantsDrift)
 
-	go processStatsMonitor.MonitorProcess()
-	go processStatsMonitor.MonitorTenantsProcessMetrics()
-	go processStatsMonitor.MonitorTenantsS3Stats()
+	tenantRestartMonitor := NewTenantRestartMonitor(configStore, asc, trc, tinv)
+	tinv.Subscribe(tenantRestartMonitor.handleTenantEvent)
+	scheduler.Schedule("tenant-restarts", every(5*time.Minute), tenantRestartMonitor.MonitorTenantsRestarts)
 
+	scheduler.checkScheduleNames(configStore.Get())
+	configStore.Subscribe(func(old, new *conf.Config) {
+		scheduler.checkScheduleNames(new)
+	})
+	return scheduler
 }
@@ -1,102 +1,121 @@
 package monitor
 
 import (
+	"ChintuIdrive/storage-node-watchdog/alert"
 	"ChintuIdrive/storage-node-watchdog/clients"
 	"ChintuIdrive/storage-node-watchdog/collector"
 	"ChintuIdrive/storage-node-watchdog/dto"
-	"log"
+	"context"
+	"log/slog"
 	"time"
 )
 
 type PrcessStatsMonitor struct {
-	apiServerClient     *clients.APIserverClient
-	controllerClient    *clients.ControllerClient
-	procMetricCollector *collector.ProcesMetricsCollector
-	s3MetricsCollector  *collector.S3MetricCollector
+	tenantInventory       *collector.TenantInventory
+	controllerClient      *clients.ControllerClient
+	procMetricCollector   *collector.ProcesMetricsCollector
+	s3MetricsCollector    *collector.S3MetricCollector
+	requestStatsCollector *collector.TenantRequestStatsCollector
 }
 
-func NewPrcessStatsMonitor(pmc *collector.ProcesMetricsCollector, s3mc *collector.S3MetricCollector, ac *clients.APIserverClient, cc *clients.ControllerClient) *PrcessStatsMonitor {
+func NewPrcessStatsMonitor(pmc *collector.ProcesMetricsCollector, s3mc *collector.S3MetricCollector, trsc *collector.TenantRequestStatsCollector, tinv *collector.TenantInventory, cc *clients.ControllerClient) *PrcessStatsMonitor {
 	return &PrcessStatsMonitor{
-		procMetricCollector: pmc,
-		s3MetricsCollector:  s3mc,
-		apiServerClient:     ac,
-		controllerClient:    cc,
+		procMetricCollector:   pmc,
+		s3MetricsCollector:    s3mc,
+		requestStatsCollector: trsc,
+		tenantInventory:       tinv,
+		controllerClient:      cc,
 	}
 }
 
 //var monitoredProcesses = []string{"minio", "e2_node_controller_service", "trash-cleaner-service", "rclone", "kes", "vault", "load-simulator"}
 
-func (psa *PrcessStatsMonitor) MonitorProcess() {
-	for {
-		processMetrics := psa.procMetricCollector.CollectProcessMetrics()
-		for _, metric := range processMetrics {
-			// analyze metric and notify to admin using api server api
-			log.Printf("analyzing prcess:%s", metric.Name)
-			log.Printf("Process: %s, PID: %d, CPU Usage: %.2f%%, Memory Usage: %.2f%%", metric.Name, metric.PID, metric.CPUUsage, metric.MemUsage)
-		}
-
-		time.Sleep(15 * time.Minute) // Adjust interval as needed
+func (psa *PrcessStatsMonitor) MonitorProcess(ctx context.Context) {
+	processMetrics := psa.procMetricCollector.CollectProcessMetrics()
+	for _, metric := range processMetrics {
+		// analyze metric and notify to admin using api server api
+		slog.Info("Process metrics", "process", metric.Name, "pid", metric.PID, "cpu_usage", metric.CPUUsage, "mem_usage", metric.MemUsage)
 	}
 }
 
-func (psm *PrcessStatsMonitor) MonitorTenantsProcessMetrics() {
-
-	for {
-		tenantsFromApiServer, err := psm.apiServerClient.GetTenatsListFromApiServer()
+func (psm *PrcessStatsMonitor) MonitorTenantsProcessMetrics(ctx context.Context) {
+	tenants := psm.tenantInventory.GetTenants()
+	runningTenats := psm.procMetricCollector.CollectRunningTenantProcMetrics()
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		tenantProcessInfo, err := psm.controllerClient.GetTenantWithProcessInfo(ctx, tenant)
 		if err != nil {
-			//notify watchdog not able to fetch tenantlist from api server
-			log.Printf("Failed to fetch tenant list from API server: %v", err)
+			//notify why it is not able to get
+			slog.Warn("Tenant from API server not found in controller tenant list", "tenant", tenant.DNS)
+			continue
+		}
+		requestStats := psm.requestStatsCollector.CollectTenantRequestStats(*tenantProcessInfo)
+		checkTenantRequestStats(requestStats)
+
+		runningTenant, found := findRunningMinioProc(*tenantProcessInfo, runningTenats)
+		if !found {
+			// minio process not found for tenant in running minio process list
+			slog.Warn("Minio process of tenant not found in running minio process list", "tenant", tenantProcessInfo.DNS)
+			continue
 		}
-		runningTenats := psm.procMetricCollector.CollectRunningTenantProcMetrics()
-		for _, tenant := range tenantsFromApiServer {
-			tenantProcessInfo, err := psm.controllerClient.GetTenantWithProcessInfo(tenant)
-			if err != nil {
-				//notify why it is not able to get
-				log.Printf("Tenant %s from API server not found in controller tenant list", tenant.DNS)
-				continue
-			}
-			runningTenant, found := findRunningMinioProc(*tenantProcessInfo, runningTenats)
-			if !found {
-				// minio process not found for tenant in running minio process list
-				log.Printf("Minio process for tenant %s not found in running minio process list", tenantProcessInfo.DNS)
-				continue
-			}
 
-			// analyse metrics and notify if any metric reached the threshold
+		// analyse metrics and notify if any metric reached the threshold
 
-			log.Printf("Tenant: %s, PID: %d, CPU Usage: %.2f%%, Memory Usage: %.2f%%", tenantProcessInfo.DNS, runningTenant.PID, runningTenant.CPUUsage, runningTenant.MemUsage)
-		}
-		time.Sleep(15 * time.Minute) // Adjust interval as needed
+		slog.Info("Tenant process metrics", "tenant", tenantProcessInfo.DNS, "pid", runningTenant.PID, "cpu_usage", runningTenant.CPUUsage, "mem_usage", runningTenant.MemUsage)
 	}
-
 }
 
-func (psm *PrcessStatsMonitor) MonitorTenantsS3Stats() {
-
-	for {
-		tenantsFromApiServer, err := psm.apiServerClient.GetTenatsListFromApiServer()
+func (psm *PrcessStatsMonitor) MonitorTenantsS3Stats(ctx context.Context) {
+	tenants := psm.tenantInventory.GetTenants()
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		s3stats, err := psm.s3MetricsCollector.CollectS3Metrics(ctx, tenant)
 		if err != nil {
-			//notify watchdog not able to fetch tenantlist from api server
-			log.Printf("Failed to fetch tenant list from API server: %v", err)
+			//Notify it why it is not able to get the s3 metics
+			slog.Warn("Failed to collect S3 metrics", "tenant", tenant.DNS, "error", err)
+			continue
 		}
-		for _, tenant := range tenantsFromApiServer {
-			s3stats, err := psm.s3MetricsCollector.CollectS3Metrics(tenant)
-			if err != nil {
-				//Notify it why it is not able to get the s3 metics
-				log.Printf("Failed to collect S3 metrics for tenant %s: %v", tenant.DNS, err)
-				continue
-			}
-			// analyze s3stats and notify if any metric reach the threshold
-			log.Printf("Tenant: %s, BucketCount: %d, Time taken in bucket listing: %v", s3stats.DNS, s3stats.BucketsCount, s3stats.BucketListingDuration)
-			for bucket, objMetric := range s3stats.ObjectMetricsMap {
+		// analyze s3stats and notify if any metric reach the threshold
+		logS3Metrics(s3stats.Public)
+		logS3Metrics(s3stats.Local)
+	}
+}
 
-				log.Printf("Tenant: %s, Bucket: %s, ObjectCount %d, Time taken in object listing: %v", s3stats.DNS, bucket, objMetric.ObjectsCount, objMetric.ObjecttListingDuration.Seconds())
-			}
-		}
-		time.Sleep(15 * time.Minute) // Adjust interval as needed
+func checkTenantRequestStats(requestStats *collector.TenantRequestStats) {
+	if requestStats.Interval == 0 {
+		// first sample only sets the baseline
+		return
+	}
+	slog.Info("Tenant request stats", "tenant", requestStats.DNS, "request_rate", requestStats.RequestRate,
+		"failed_s3_health_checks", requestStats.FailedS3HealthChecks, "failed_s3_health_checks_delta", requestStats.FailedS3HealthChecksDelta,
+		"error_stat", requestStats.ErrorStat, "error_stat_delta", requestStats.ErrorStatDelta)
 
+	if requestStats.FailedS3HealthChecksDelta > 0 {
+		alert.Raise("tenant-s3-health-checks/"+requestStats.DNS, "Tenant %s failed %d more S3 health checks in the last %v, total %d",
+			requestStats.DNS, requestStats.FailedS3HealthChecksDelta, requestStats.Interval.Round(time.Second), requestStats.FailedS3HealthChecks)
+	}
+	if requestStats.ErrorStatDelta > 0 {
+		alert.Raise("tenant-error-stat/"+requestStats.DNS, "Tenant %s error stat rose by %d in the last %v, total %d",
+			requestStats.DNS, requestStats.ErrorStatDelta, requestStats.Interval.Round(time.Second), requestStats.ErrorStat)
+	}
+	if requestStats.PreviousRequestRate > 0 && requestStats.RequestsDelta == 0 {
+		alert.Raise("tenant-request-rate/"+requestStats.DNS, "Tenant %s request rate dropped to zero from %.2f/s", requestStats.DNS, requestStats.PreviousRequestRate)
 	}
+}
+
+func logS3Metrics(s3stats *collector.S3Metrics) {
+	if s3stats == nil {
+		return
+	}
+	slog.Info("S3 metrics", "tenant", s3stats.DNS, "endpoint", s3stats.Endpoint, "buckets", s3stats.BucketsCount, "bucket_listing_duration", s3stats.BucketListingDuration)
+	for bucket, objMetric := range s3stats.ObjectMetricsMap {
 
+		slog.Info("S3 bucket metrics", "tenant", s3stats.DNS, "endpoint", s3stats.Endpoint, "bucket", bucket, "objects", objMetric.ObjectsCount, "object_listing_duration", objMetric.ObjecttListingDuration)
+	}
 }
 
 func findRunningMinioProc(tenant dto.TenatWithProcessInfo, minioMetrics []collector.TenantProcessMetrics) (collector.TenantProcessMetrics, bool) {
@@ -0,0 +1,188 @@
+package monitor
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"log/slog"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScheduleStatus is what the scheduler knows about one monitor.
+type ScheduleStatus struct {
+	Name         string              `json:"name"`
+	Schedule     conf.ScheduleConfig `json:"schedule"`
+	Running      bool                `json:"running"`
+	LastRun      time.Time           `json:"last_run"`      // start of the last cycle
+	LastDuration conf.Duration       `json:"last_duration"` // of the last finished cycle
+	NextRun      time.Time           `json:"next_run"`
+	Runs         int                 `json:"runs"`
+	Overruns     int                 `json:"overruns"` // cycles that took longer than the interval
+	Timeouts     int                 `json:"timeouts"` // cycles cancelled by the timeout
+}
+
+type scheduledMonitor struct {
+	schedule func(config *conf.Config) conf.ScheduleConfig
+	cycle    func(ctx context.Context)
+	started  bool
+	status   ScheduleStatus
+}
+
+// Scheduler runs each monitor cycle on the schedule configured for it in
+// config.json, read again before every cycle.
+type Scheduler struct {
+	configStore *conf.ConfigStore
+	supervisor  *Supervisor
+	lock        sync.Mutex
+	monitors    map[string]*scheduledMonitor
+}
+
+func NewScheduler(configStore *conf.ConfigStore, supervisor *Supervisor) *Scheduler {
+	return &Scheduler{
+		configStore: configStore,
+		supervisor:  supervisor,
+		monitors:    make(map[string]*scheduledMonitor),
+	}
+}
+
+// every is the default schedule of a monitor that runs each interval.
+func every(interval time.Duration) func(config *conf.Config) conf.ScheduleConfig {
+	return func(config *conf.Config) conf.ScheduleConfig {
+		return conf.ScheduleConfig{Interval: conf.NewDuration(interval)}
+	}
+}
+
+// Schedule starts running cycle. defaults gives the schedule for the fields
+// config.json does not set.
+func (s *Scheduler) Schedule(name string, defaults func(config *conf.Config) conf.ScheduleConfig, cycle func(ctx context.Context)) {
+	sm := &scheduledMonitor{cycle: cycle, status: ScheduleStatus{Name: name}}
+	sm.schedule = func(config *conf.Config) conf.ScheduleConfig {
+		return config.GetSchedule(name, defaults(config))
+	}
+	s.lock.Lock()
+	s.monitors[name] = sm
+	s.lock.Unlock()
+
+	s.supervisor.Go(name, func(ctx context.Context) {
+		s.run(ctx, sm)
+	})
+}
+
+// run runs the cycles of a monitor until ctx is done. After a panic the
+// supervisor calls it again, then the initial delay is skipped.
+func (s *Scheduler) run(ctx context.Context, sm *scheduledMonitor) {
+	schedule := sm.schedule(s.configStore.Get())
+	s.lock.Lock()
+	sm.status.Schedule = schedule
+	sm.status.Running = false
+	s.lock.Unlock()
+	if !sm.started {
+		sm.started = true
+		if !s.waitUntil(ctx, sm, time.Now().Add(schedule.InitialDelay.Duration+jitter(schedule.Jitter.Duration))) {
+			return
+		}
+	}
+
+	for {
+		schedule = sm.schedule(s.configStore.Get())
+		start := time.Now()
+		s.lock.Lock()
+		sm.status.Schedule = schedule
+		sm.status.Running = true
+		sm.status.LastRun = start
+		s.lock.Unlock()
+
+		timedOut := runCycle(ctx, schedule.Timeout.Duration, sm.cycle)
+		duration := time.Since(start)
+		next := start.Add(schedule.Interval.Duration + jitter(schedule.Jitter.Duration))
+		overrun := duration > schedule.Interval.Duration
+
+		s.lock.Lock()
+		sm.status.Running = false
+		sm.status.LastDuration = conf.NewDuration(duration)
+		sm.status.Runs++
+		if overrun {
+			sm.status.Overruns++
+		}
+		if timedOut {
+			sm.status.Timeouts++
+		}
+		s.lock.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+		// the cycle finished without a panic
+		alert.Resolve("monitor-panic/" + sm.status.Name)
+		if timedOut {
+			alert.Raise("monitor-timeout/"+sm.status.Name, "Monitor %s cycle was cancelled after its timeout %v", sm.status.Name, schedule.Timeout)
+		} else if overrun {
+			alert.Raise("monitor-overrun/"+sm.status.Name, "Monitor %s cycle took %v, longer than its interval %v", sm.status.Name, duration.Round(time.Millisecond), schedule.Interval)
+		}
+		if !s.waitUntil(ctx, sm, next) {
+			return
+		}
+	}
+}
+
+// runCycle runs one cycle, cancelling it after timeout if that is set. It
+// reports whether the timeout cancelled the cycle.
+func runCycle(ctx context.Context, timeout time.Duration, cycle func(ctx context.Context)) bool {
+	if timeout <= 0 {
+		cycle(ctx)
+		return false
+	}
+	cycleCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cycle(cycleCtx)
+	return cycleCtx.Err() == context.DeadlineExceeded
+}
+
+func (s *Scheduler) waitUntil(ctx context.Context, sm *scheduledMonitor, next time.Time) bool {
+	s.lock.Lock()
+	sm.status.NextRun = next
+	s.lock.Unlock()
+	return sleep(ctx, time.Until(next))
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// Status returns the schedule status of every monitor, sorted by name.
+func (s *Scheduler) Status() []ScheduleStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	statuses := make([]ScheduleStatus, 0, len(s.monitors))
+	for _, sm := range s.monitors {
+		statuses = append(statuses, sm.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Wait waits at most timeout for the monitors to finish their cycle after
+// the context is done. It reports whether they all did.
+func (s *Scheduler) Wait(timeout time.Duration) bool {
+	return s.supervisor.Wait(timeout)
+}
+
+// checkScheduleNames warns about schedules in config.json for monitors that
+// do not exist, most likely a typo.
+func (s *Scheduler) checkScheduleNames(config *conf.Config) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for name := range config.Schedules {
+		if _, found := s.monitors[name]; !found {
+			slog.Warn("Schedule matches no monitor, it is ignored", "schedule", name)
+		}
+	}
+}
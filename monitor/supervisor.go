@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// monitorRestartDelay is how long a monitor that panicked waits before it is
+// started again.
+const monitorRestartDelay = 10 * time.Second
+
+// Supervisor runs the monitors until its context is done and restarts a
+// monitor that panics.
+type Supervisor struct {
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+func NewSupervisor(ctx context.Context) *Supervisor {
+	return &Supervisor{ctx: ctx}
+}
+
+// Go runs monitor in a goroutine. The monitor must return once ctx is done,
+// finishing the cycle it is in.
+func (s *Supervisor) Go(name string, monitor func(ctx context.Context)) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for s.run(name, monitor) {
+			if !sleep(s.ctx, monitorRestartDelay) {
+				return
+			}
+			slog.Warn("Restarting monitor", "monitor", name)
+		}
+	}()
+}
+
+// run reports whether the monitor panicked.
+func (s *Supervisor) run(name string, monitor func(ctx context.Context)) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			alert.RaiseUntilResolved("monitor-panic/"+name, "Monitor %s panicked, restarting it in %v: %v\n%s", name, monitorRestartDelay, r, debug.Stack())
+			panicked = true
+		}
+	}()
+	monitor(s.ctx)
+	return false
+}
+
+// Wait waits at most timeout for the monitors to return after the context is
+// done. It reports whether they all did.
+func (s *Supervisor) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// sleep waits for d or until ctx is done, it reports whether ctx is still
+// running.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
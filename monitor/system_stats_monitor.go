@@ -1,8 +1,10 @@
 package monitor
 
 import (
+	"ChintuIdrive/storage-node-watchdog/alert"
 	"ChintuIdrive/storage-node-watchdog/collector"
-	"log"
+	"context"
+	"strings"
 	"sync"
 	"time"
 
@@ -40,26 +42,23 @@ func NewSystemStatsMonitor(ssc *collector.SystemStatsCollector) *SystemStatsMoni
 	}
 }
 
-func (ssm *SystemStatsMonitor) MonitorSystemStats() {
-	for {
-		systemStats := ssm.ssc.CollectSystemMetrics()
-		// Log the system metrics
-		// log.Printf("System Stats")
-		// log.Printf("CPU Usage: %.2f%%, RAM Usage:  %.2f%%, Total RAM: %d MB, Active Connections: %d",
-		// 	systemStats.CPUStats.CPUUsage, systemStats.RAMStats.UsedPercent, systemStats.RAMStats.Total, systemStats.ActiveConnCount)
-		// log.Printf("Load Avg (1m): %.2f, (5m): %.2f, (15m): %.2f",
-		// 	systemStats.CPUStats.AvgLoad1, systemStats.CPUStats.AvgLoad5, systemStats.CPUStats.AvgLoad15)
-		checkMetric(systemStats.CPUStats.AvgLoad1, HighAvgLoadThreshold, &lastAvgLoadAlert, "Avg Load1")
-		checkMetric(systemStats.CPUStats.CPUUsage, CPUusageThreshold, &lastCPUAlert, "CPU Usage")
-		checkMetric(systemStats.CPUStats.CPUUsage, CPUusageThreshold, &lastCPUAlert, "CPU Usage")
-		checkMetric(systemStats.RAMStats.UsedPercent, MemoryUsageThreshold, &lastMemAlert, "Memory Usage")
-
-		for disk, diskStat := range systemStats.DiskStatsMap {
-			//checkMetric(diskStat.DiskUsageStat.UsedPercent, DiskUsageThreshold, &lastMemAlert, "Disk Usage on "+disk)
-			checkDiskUsage(disk, diskStat.DiskUsageStat)
-			checkDiskIO(disk, diskStat.DiskIOStat)
-		}
-		time.Sleep(15 * time.Second) // Adjust as needed
+func (ssm *SystemStatsMonitor) MonitorSystemStats(ctx context.Context) {
+	systemStats := ssm.ssc.CollectSystemMetrics()
+	// Log the system metrics
+	// log.Printf("System Stats")
+	// log.Printf("CPU Usage: %.2f%%, RAM Usage:  %.2f%%, Total RAM: %d MB, Active Connections: %d",
+	// 	systemStats.CPUStats.CPUUsage, systemStats.RAMStats.UsedPercent, systemStats.RAMStats.Total, systemStats.ActiveConnCount)
+	// log.Printf("Load Avg (1m): %.2f, (5m): %.2f, (15m): %.2f",
+	// 	systemStats.CPUStats.AvgLoad1, systemStats.CPUStats.AvgLoad5, systemStats.CPUStats.AvgLoad15)
+	checkMetric(systemStats.CPUStats.AvgLoad1, HighAvgLoadThreshold, &lastAvgLoadAlert, "Avg Load1")
+	checkMetric(systemStats.CPUStats.CPUUsage, CPUusageThreshold, &lastCPUAlert, "CPU Usage")
+	checkMetric(systemStats.CPUStats.CPUUsage, CPUusageThreshold, &lastCPUAlert, "CPU Usage")
+	checkMetric(systemStats.RAMStats.UsedPercent, MemoryUsageThreshold, &lastMemAlert, "Memory Usage")
+
+	for disk, diskStat := range systemStats.DiskStatsMap {
+		//checkMetric(diskStat.DiskUsageStat.UsedPercent, DiskUsageThreshold, &lastMemAlert, "Disk Usage on "+disk)
+		checkDiskUsage(disk, diskStat.DiskUsageStat)
+		checkDiskIO(disk, diskStat.DiskIOStat)
 	}
 }
 
@@ -74,7 +73,7 @@ func checkAlerts(loadAvg1, cpuUsage float64, memUsage float64) {
 		if highLoadStartTime.IsZero() {
 			highLoadStartTime = time.Now() // Start tracking high load time
 		} else if time.Since(highLoadStartTime) >= HighLoadDuration {
-			log.Println("[ALERT] System Load1 has been high for 5+ minutes!")
+			alert.Raise("system/load1", "System Load1 has been high for 5+ minutes!")
 			highLoadStartTime = time.Time{} // Reset timer after restart
 		}
 	} else {
@@ -82,13 +81,13 @@ func checkAlerts(loadAvg1, cpuUsage float64, memUsage float64) {
 	}
 	// CPU Alert
 	if cpuUsage > CPUusageThreshold && now.Sub(lastCPUAlert) > AlertCooldown {
-		log.Printf("[ALERT] High CPU Usage: %.2f%%", cpuUsage)
+		alert.Raise("system/cpu-usage", "High CPU Usage: %.2f%%", cpuUsage)
 		lastCPUAlert = now
 	}
 
 	// Memory Alert
 	if memUsage > MemoryUsageThreshold && now.Sub(lastMemAlert) > AlertCooldown {
-		log.Printf("[ALERT] High Memory Usage: %.2f%%", memUsage)
+		alert.Raise("system/memory-usage", "High Memory Usage: %.2f%%", memUsage)
 		lastMemAlert = now
 	}
 }
@@ -102,7 +101,7 @@ func checkMetric(metricValue float64, threshold float64, lastAlertTime *time.Tim
 		if lastAlertTime.IsZero() {
 			*lastAlertTime = now // Start tracking high metric usage time
 		} else if now.Sub(*lastAlertTime) >= HighLoadDuration {
-			log.Printf("[ALERT] High %s: %.2f%% for 5+ minutes!", metricName, metricValue)
+			alert.Raise("system/"+strings.ReplaceAll(strings.ToLower(metricName), " ", "-"), "High %s: %.2f%% for 5+ minutes!", metricName, metricValue)
 			*lastAlertTime = time.Time{} // Reset timer after alert
 		}
 	} else {
@@ -121,25 +120,25 @@ func checkDiskIO(disk string, diskIOStat disk.IOCountersStat) {
 	now := time.Now()
 	// Check read bytes
 	if diskIOStat.ReadBytes > ReadBytesThreshold && now.Sub(lastReadBytesAlert) > AlertCooldown {
-		log.Printf("[ALERT] High Disk Read Bytes: %d bytes on %s", diskIOStat.ReadBytes, disk)
+		alert.Raise("disk-read-bytes/"+disk, "High Disk Read Bytes: %d bytes on %s", diskIOStat.ReadBytes, disk)
 		lastReadBytesAlert = now
 	}
 
 	// Check write bytes
 	if diskIOStat.WriteBytes > WriteBytesThreshold && now.Sub(lastWriteBytesAlert) > AlertCooldown {
-		log.Printf("[ALERT] High Disk Write Bytes: %d bytes on %s", diskIOStat.WriteBytes, disk)
+		alert.Raise("disk-write-bytes/"+disk, "High Disk Write Bytes: %d bytes on %s", diskIOStat.WriteBytes, disk)
 		lastWriteBytesAlert = now
 	}
 
 	// Check read IOPS
 	if diskIOStat.ReadCount > IOPSReadThreshold && now.Sub(lastReadCountAlert) > AlertCooldown {
-		log.Printf("[ALERT] High Disk Read IOPS: %d on %s", diskIOStat.ReadCount, disk)
+		alert.Raise("disk-read-iops/"+disk, "High Disk Read IOPS: %d on %s", diskIOStat.ReadCount, disk)
 		lastReadCountAlert = now
 	}
 
 	// Check write IOPS
 	if diskIOStat.WriteCount > IOPSWriteThreshold && now.Sub(lastWriteCountAlert) > AlertCooldown {
-		log.Printf("[ALERT] High Disk Write IOPS: %d on %s", diskIOStat.WriteCount, disk)
+		alert.Raise("disk-write-iops/"+disk, "High Disk Write IOPS: %d on %s", diskIOStat.WriteCount, disk)
 		lastWriteCountAlert = now
 	}
 }
@@ -150,7 +149,7 @@ func checkDiskUsage(diskName string, diskUsageStat *collector.DiskUsageStat) {
 
 	now := time.Now()
 	if diskUsageStat.UsedPercent > DiskUsageThreshold && now.Sub(diskUsageAlert) > AlertCooldown {
-		log.Printf("[ALERT] High Disk Usage on %s: %.2f%%", diskName, diskUsageStat.UsedPercent)
+		alert.Raise("disk-usage/"+diskName, "High Disk Usage on %s: %.2f%%", diskName, diskUsageStat.UsedPercent)
 		diskUsageAlert = now
 	}
 }
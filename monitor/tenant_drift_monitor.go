@@ -0,0 +1,76 @@
+package monitor
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const defaultTenantDriftGracePeriod = 30 * time.Minute
+
+type TenantDriftMonitor struct {
+	configStore          *conf.ConfigStore
+	apiServerClient      *clients.APIserverClient
+	tenantInventory      *collector.TenantInventory
+	tenantDriftCollector *collector.TenantDriftCollector
+}
+
+func NewTenantDriftMonitor(configStore *conf.ConfigStore, asc *clients.APIserverClient, tdc *collector.TenantDriftCollector, tinv *collector.TenantInventory) *TenantDriftMonitor {
+	return &TenantDriftMonitor{
+		configStore:          configStore,
+		apiServerClient:      asc,
+		tenantInventory:      tinv,
+		tenantDriftCollector: tdc,
+	}
+}
+
+// MonitorTenantsDrift keeps tracking drift while the tenant list is not
+// fresh from the API server, but does not alert on it, the desired state may
+// be out of date.
+func (tdm *TenantDriftMonitor) MonitorTenantsDrift(ctx context.Context) {
+	listStatus := tdm.apiServerClient.GetTenantListStatus()
+	if listStatus.Source != clients.TenantListFromAPIServer {
+		slog.Warn("Tenant list is not from the API server, not alerting on drift", "source", listStatus.Source, "age", listStatus.Age)
+	}
+	tenants := tdm.tenantInventory.GetTenants()
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		tenantDrift, err := tdm.tenantDriftCollector.CollectTenantDrift(ctx, tenant)
+		if err != nil {
+			slog.Warn("Failed to check configuration drift", "tenant", tenant.DNS, "error", err)
+			continue
+		}
+		if listStatus.Source == clients.TenantListFromAPIServer {
+			tdm.checkTenantDrift(tenantDrift)
+		}
+	}
+}
+
+// handleTenantEvent forgets the drift state of tenants removed from the inventory.
+func (tdm *TenantDriftMonitor) handleTenantEvent(event collector.TenantEvent) {
+	if event.Type == collector.TenantRemoved {
+		tdm.tenantDriftCollector.RemoveTenant(event.Tenant.DNS)
+	}
+}
+
+func (tdm *TenantDriftMonitor) checkTenantDrift(tenantDrift *collector.TenantDrift) {
+	gracePeriod := tdm.configStore.Get().TenantDriftGracePeriod.Duration
+	if gracePeriod == 0 {
+		gracePeriod = defaultTenantDriftGracePeriod
+	}
+	for _, field := range tenantDrift.Fields {
+		driftDuration := tenantDrift.CheckedAt.Sub(field.Since)
+		if driftDuration < gracePeriod {
+			slog.Info("Tenant configuration differs from API server", "tenant", tenantDrift.DNS, "field", field.Field, "desired", field.Desired, "running", field.Running)
+			continue
+		}
+		alert.Raise("tenant-drift/"+tenantDrift.DNS+"/"+field.Field, "Tenant %s %s has diverged from the API server for %v: desired %q, running %q",
+			tenantDrift.DNS, field.Field, driftDuration.Round(time.Second), field.Desired, field.Running)
+	}
+}
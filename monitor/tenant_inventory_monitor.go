@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"log/slog"
+	"time"
+)
+
+const defaultTenantInventoryRefreshInterval = 5 * time.Minute
+
+type TenantInventoryMonitor struct {
+	tenantInventory *collector.TenantInventory
+}
+
+func NewTenantInventoryMonitor(tinv *collector.TenantInventory) *TenantInventoryMonitor {
+	return &TenantInventoryMonitor{
+		tenantInventory: tinv,
+	}
+}
+
+// MonitorTenantInventory keeps the tenant inventory up to date.
+func (tim *TenantInventoryMonitor) MonitorTenantInventory(ctx context.Context) {
+	if err := tim.tenantInventory.Refresh(ctx); err != nil {
+		//notify watchdog not able to fetch tenantlist from api server
+		slog.Error("Failed to refresh tenant inventory, keeping the previous one", "error", err)
+	}
+}
+
+// tenantInventorySchedule refreshes every tenant-inventory-refresh-interval.
+// The first refresh is done before the monitors start, so it waits an
+// interval first.
+func tenantInventorySchedule(config *conf.Config) conf.ScheduleConfig {
+	interval := config.TenantInventoryRefreshInterval
+	if interval.Duration == 0 {
+		interval = conf.NewDuration(defaultTenantInventoryRefreshInterval)
+	}
+	return conf.ScheduleConfig{Interval: interval, InitialDelay: interval}
+}
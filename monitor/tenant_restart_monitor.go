@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"ChintuIdrive/storage-node-watchdog/alert"
+	"ChintuIdrive/storage-node-watchdog/clients"
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"ChintuIdrive/storage-node-watchdog/conf"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type TenantRestartMonitor struct {
+	configStore            *conf.ConfigStore
+	apiServerClient        *clients.APIserverClient
+	tenantInventory        *collector.TenantInventory
+	tenantRestartCollector *collector.TenantRestartCollector
+}
+
+func NewTenantRestartMonitor(configStore *conf.ConfigStore, asc *clients.APIserverClient, trc *collector.TenantRestartCollector, tinv *collector.TenantInventory) *TenantRestartMonitor {
+	return &TenantRestartMonitor{
+		configStore:            configStore,
+		apiServerClient:        asc,
+		tenantInventory:        tinv,
+		tenantRestartCollector: trc,
+	}
+}
+
+// MonitorTenantsRestarts keeps the restart history while the tenant list is
+// not fresh from the API server, but does not alert, the restart requests in
+// it may be out of date.
+func (trm *TenantRestartMonitor) MonitorTenantsRestarts(ctx context.Context) {
+	listStatus := trm.apiServerClient.GetTenantListStatus()
+	if listStatus.Source != clients.TenantListFromAPIServer {
+		slog.Warn("Tenant list is not from the API server, not alerting on restarts", "source", listStatus.Source, "age", listStatus.Age)
+	}
+	tenants := trm.tenantInventory.GetTenants()
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		restartState, err := trm.tenantRestartCollector.CollectTenantRestartState(ctx, tenant)
+		if err != nil {
+			slog.Warn("Failed to get restart state", "tenant", tenant.DNS, "error", err)
+			continue
+		}
+		if listStatus.Source == clients.TenantListFromAPIServer {
+			trm.checkTenantRestarts(restartState)
+		}
+	}
+	// restarts are detected by comparing consecutive checks, so this runs
+	// more often than the other tenant monitors
+}
+
+// handleTenantEvent forgets the restart history of tenants removed from the inventory.
+func (trm *TenantRestartMonitor) handleTenantEvent(event collector.TenantEvent) {
+	if event.Type == collector.TenantRemoved {
+		trm.tenantRestartCollector.RemoveTenant(event.Tenant.DNS)
+	}
+}
+
+func (trm *TenantRestartMonitor) checkTenantRestarts(state *collector.TenantRestartState) {
+	threshold := trm.configStore.Get().TenantRestartThreshold
+	now := state.CheckedAt
+
+	if !state.RestartRequestedAt.IsZero() && threshold.RestartDeadline.Duration > 0 {
+		pending := now.Sub(state.RestartRequestedAt)
+		if pending >= threshold.RestartDeadline.Duration {
+			alert.Raise("tenant-restart-pending/"+state.DNS, "Tenant %s restart requested by API server %v ago was not carried out (force: %v)",
+				state.DNS, pending.Round(time.Second), state.ForceRestart)
+		}
+	}
+
+	if !state.RestartInProcessSince.IsZero() && threshold.RestartInProcessDeadline.Duration > 0 {
+		stuck := now.Sub(state.RestartInProcessSince)
+		if stuck >= threshold.RestartInProcessDeadline.Duration {
+			alert.Raise("tenant-restart-stuck/"+state.DNS, "Tenant %s has been in RestartInProcess for %v", state.DNS, stuck.Round(time.Second))
+		}
+	}
+
+	if threshold.FlapCount > 0 && threshold.FlapWindow.Duration > 0 {
+		restarts := state.RestartsSince(now.Add(-threshold.FlapWindow.Duration))
+		if restarts >= threshold.FlapCount {
+			alert.Raise("tenant-flapping/"+state.DNS, "Tenant %s restarted %d times in the last %v", state.DNS, restarts, threshold.FlapWindow.Duration)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package monitor
+
+import (
+	"ChintuIdrive/storage-node-watchdog/collector"
+	"context"
+	"log/slog"
+)
+
+type TenantUsageMonitor struct {
+	tenantInventory      *collector.TenantInventory
+	tenantUsageCollector *collector.TenantUsageCollector
+	lastUsage            map[string]*collector.TenantUsage
+}
+
+func NewTenantUsageMonitor(tuc *collector.TenantUsageCollector, tinv *collector.TenantInventory) *TenantUsageMonitor {
+	return &TenantUsageMonitor{
+		tenantInventory:      tinv,
+		tenantUsageCollector: tuc,
+		lastUsage:            make(map[string]*collector.TenantUsage),
+	}
+}
+
+func (tum *TenantUsageMonitor) MonitorTenantsUsage(ctx context.Context) {
+	tenants := tum.tenantInventory.GetTenants()
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		usage, err := tum.tenantUsageCollector.CollectTenantUsage(ctx, tenant)
+		if err != nil {
+			slog.Warn("Failed to collect usage", "tenant", tenant.DNS, "error", err)
+			continue
+		}
+		slog.Info("Tenant usage", "tenant", usage.DNS, "buckets", usage.BucketsCount, "objects", usage.ObjectsCount, "versions", usage.VersionsCount, "size_bytes", usage.ObjectsTotalSize)
+		if last, found := tum.lastUsage[tenant.DNS]; found {
+			slog.Info("Tenant usage growth", "tenant", usage.DNS, "size_growth_bytes", int64(usage.ObjectsTotalSize)-int64(last.ObjectsTotalSize),
+				"objects_growth", int64(usage.ObjectsCount)-int64(last.ObjectsCount), "since", last.LastUpdate)
+		}
+		tum.lastUsage[tenant.DNS] = usage
+	}
+}